@@ -0,0 +1,72 @@
+package env_logger
+
+import (
+	_ "embed"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+//go:embed ui/index.html
+var uiIndex []byte
+
+// AdminHandler returns the log config, level, tail and (with the logpprof tag) pprof endpoints of the profile server.
+// Mount it into an existing server, eg. mux.Handle("/debug/log/", http.StripPrefix("/debug/log", log.AdminHandler()))
+func AdminHandler() http.Handler {
+	return requireAdminAuth(newAdminMux())
+}
+
+func newAdminMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", handleUI)
+	mux.HandleFunc("/logstring", handleLogString)
+	mux.HandleFunc("/loglevels", handleLogLevels)
+	mux.HandleFunc("/loglevels/", handleLogLevels)
+	mux.HandleFunc("/flags/", handleFlags)
+	mux.HandleFunc("/tail", handleTail)
+	mux.HandleFunc("/recent", handleRecent)
+	mux.HandleFunc("/trace/start", handleTraceStart)
+	mux.HandleFunc("/trace/stop", handleTraceStop)
+	registerProfileHandlers(mux)
+	return mux
+}
+
+func handleLogString(w http.ResponseWriter, r *http.Request) {
+	// function to allow dynamicaly setting the logstring
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "Error: "+err.Error())
+		return
+	}
+	debugConfig := strings.TrimSpace(string(body))
+	if ttlParam := r.URL.Query().Get("ttl"); ttlParam != "" {
+		// temporary config, the previous one is restored after ttl
+		ttl, err := time.ParseDuration(ttlParam)
+		if err == nil {
+			err = SetGlobalDebugConfigFor(debugConfig, ttl)
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, "Error: "+err.Error())
+			return
+		}
+		fmt.Fprintf(w, "New log config for %s: %s", ttl, debugConfig)
+		return
+	}
+	SetGlobalDebugConfig(debugConfig)
+
+	fmt.Fprintf(w, "New log config: %s", debugConfig)
+}
+
+// handleUI serves the web ui to change levels and tail the log from a browser
+func handleUI(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" && r.URL.Path != "" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(uiIndex)
+}
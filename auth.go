@@ -0,0 +1,128 @@
+package env_logger
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+// profileTokenEnv names the environment variable holding the bearer token of the profile server.
+// It is deliberately not part of the log config, that one gets logged and returned by the endpoints
+const profileTokenEnv = "LOG_PPTOKEN"
+
+type adminAuth struct {
+	allowList  string
+	tokenHash  [sha256.Size]byte
+	hasToken   bool
+	restricted bool
+	allowed    []*net.IPNet
+}
+
+var currentAdminAuth adminAuth
+var adminAuthMu sync.RWMutex
+
+// configureAdminAuth reads the token from the environment and parses the ppallow list (separated by ;)
+func configureAdminAuth(allowList string) {
+	auth := adminAuth{allowList: allowList}
+	if token := os.Getenv(profileTokenEnv); token != "" {
+		auth.tokenHash = sha256.Sum256([]byte(token))
+		auth.hasToken = true
+	}
+
+	if allowList != "" {
+		// an allowlist without a single valid entry denies everyone instead of allowing everyone
+		auth.restricted = true
+		for _, cidr := range strings.Split(allowList, ";") {
+			if ipnet, err := parseCIDR(cidr); err == nil {
+				auth.allowed = append(auth.allowed, ipnet)
+			} else {
+				Errorf("ignoring invalid ppallow entry '%s': %v", cidr, err)
+			}
+		}
+	}
+
+	adminAuthMu.Lock()
+	currentAdminAuth = auth
+	adminAuthMu.Unlock()
+}
+
+// adminAllowList returns the ppallow list currently in effect
+func adminAllowList() string {
+	adminAuthMu.RLock()
+	defer adminAuthMu.RUnlock()
+	return currentAdminAuth.allowList
+}
+
+// parseCIDR also accepts plain addresses as single host networks
+func parseCIDR(cidr string) (*net.IPNet, error) {
+	cidr = strings.TrimSpace(cidr)
+	if !strings.Contains(cidr, "/") {
+		if ip := net.ParseIP(cidr); ip != nil {
+			bits := 8 * len(ip.To16())
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+		}
+	}
+	_, ipnet, err := net.ParseCIDR(cidr)
+	return ipnet, err
+}
+
+func (a *adminAuth) clientAllowed(r *http.Request) bool {
+	if !a.restricted {
+		return true
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		// unix socket clients have no ip address, access is controlled by the socket permissions
+		return true
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, ipnet := range a.allowed {
+		if ipnet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *adminAuth) tokenValid(r *http.Request) bool {
+	if !a.hasToken {
+		return true
+	}
+	header := r.Header.Get("Authorization")
+	if len(header) < len("Bearer ") || !strings.EqualFold(header[:len("Bearer ")], "Bearer ") {
+		return false
+	}
+	// compare hashes so neither content nor length of the token leak through timing
+	given := sha256.Sum256([]byte(header[len("Bearer "):]))
+	return subtle.ConstantTimeCompare(given[:], a.tokenHash[:]) == 1
+}
+
+// requireAdminAuth protects the admin endpoints with the client allowlist and the bearer token
+func requireAdminAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		adminAuthMu.RLock()
+		auth := currentAdminAuth
+		adminAuthMu.RUnlock()
+
+		if !auth.clientAllowed(r) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		if !auth.tokenValid(r) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="env_logger"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,97 @@
+package env_logger
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequireAdminAuth(t *testing.T) {
+	t.Setenv(profileTokenEnv, "s3cret")
+	configureAdminAuth("10.0.0.0/8;192.168.1.5")
+	defer configureAdminAuth("")
+
+	handler := requireAdminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		remoteAddr string
+		auth       string
+		status     int
+	}{
+		{"10.1.2.3:1234", "Bearer s3cret", http.StatusNoContent},
+		{"192.168.1.5:1234", "bearer s3cret", http.StatusNoContent},
+		{"10.1.2.3:1234", "Bearer wrong", http.StatusUnauthorized},
+		{"10.1.2.3:1234", "", http.StatusUnauthorized},
+		{"192.168.1.6:1234", "Bearer s3cret", http.StatusForbidden},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/logstring", nil)
+		req.RemoteAddr = test.remoteAddr
+		if test.auth != "" {
+			req.Header.Set("Authorization", test.auth)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		assert.Equal(t, test.status, rec.Code, "%s with '%s'", test.remoteAddr, test.auth)
+	}
+}
+
+func TestAllowListKeptWhileServerRuns(t *testing.T) {
+	configureQuietLoggers(t, "info,pp,ppaddr=127.0.0.1:0,ppallow=10.0.0.0/8")
+	t.Cleanup(func() { configureAdminAuth("") })
+	stopProfileServer(t)
+	url := "http://" + profileServerListenAddr().String() + "/loglevels"
+
+	status, err := getStatus(http.DefaultClient, url)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, status)
+
+	// level changes replace the whole config, the server keeps running with the allowlist
+	SetGlobalDebugConfig("debug")
+	status, err = getStatus(http.DefaultClient, url)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, status)
+
+	// only an explicit ppallow changes it
+	SetGlobalDebugConfig("debug,ppallow=127.0.0.1")
+	status, err = getStatus(http.DefaultClient, url)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+}
+
+func TestAllowListKeptForMountedHandler(t *testing.T) {
+	require.NoError(t, StopProfileServer(context.Background()))
+	configureQuietLoggers(t, "info,ppallow=10.0.0.0/8")
+	t.Cleanup(func() {
+		ResetLevelOverrides()
+		configureAdminAuth("")
+	})
+	handler := AdminHandler()
+
+	status := func(method, path, body, remoteAddr string) int {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	const outside, inside = "192.168.1.1:1234", "10.1.2.3:1234"
+
+	assert.Equal(t, http.StatusForbidden, status(http.MethodGet, "/loglevels", "", outside))
+	assert.Equal(t, http.StatusOK, status(http.MethodPost, "/logstring", "debug", inside))
+	assert.Equal(t, http.StatusForbidden, status(http.MethodGet, "/loglevels", "", outside))
+	assert.Equal(t, http.StatusOK, status(http.MethodPost, "/logstring?ttl=1m", "trace", inside))
+	assert.Equal(t, http.StatusForbidden, status(http.MethodGet, "/loglevels", "", outside))
+
+	// only an explicit ppallow= removes the allowlist
+	ResetLevelOverrides()
+	assert.Equal(t, http.StatusOK, status(http.MethodPost, "/logstring", "debug,ppallow=", inside))
+	assert.Equal(t, http.StatusOK, status(http.MethodGet, "/loglevels", "", outside))
+}
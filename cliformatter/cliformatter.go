@@ -1,8 +1,18 @@
 package cliformatter
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/mattn/go-isatty"
 	"github.com/sirupsen/logrus"
 )
 
@@ -53,10 +63,163 @@ const (
 	modifierHidden     = 8
 )
 
+// TimestampMode selects how the time of an entry is printed
+type TimestampMode int
+
+const (
+	// TimestampNone prints no time at all
+	TimestampNone TimestampMode = iota
+	// TimestampAbsolute prints the wall clock time using TimestampFormat
+	TimestampAbsolute
+	// TimestampRelative prints the time since the formatter printed its first entry
+	TimestampRelative
+)
+
+const (
+	defaultTimestampFormat = "15:04:05.000"
+	defaultModuleWidth     = 16
+	defaultFieldSeparator  = " "
+)
+
 // Formatter implements logrus.Formatter interface.
 type Formatter struct {
 	PrintFields        bool
 	DisablePrintErrors bool
+
+	// Timestamp enables printing the time of an entry in front of it
+	Timestamp TimestampMode
+	// TimestampFormat is used for absolute timestamps, defaults to 15:04:05.000
+	TimestampFormat string
+
+	// ModuleColumn prints the module in its own column instead of as field
+	ModuleColumn bool
+	// ModuleWidth is the width the module column is padded to, defaults to 16
+	ModuleWidth int
+
+	// FieldSeparator is put between the fields, defaults to a single space
+	FieldSeparator string
+
+	// ForceColors enables colors even if the output is no terminal, NO_COLOR still disables them
+	ForceColors bool
+	// DisableColors never prints ANSI escape codes
+	DisableColors bool
+	// ASCIIIcons replaces the emoji level icons for consoles that can not render them
+	ASCIIIcons bool
+	// LevelColors overrides the ANSI color code (eg. 31 for red) per level, 0 prints the level without color
+	LevelColors map[logrus.Level]int
+	// LevelIcons overrides the icon per level
+	LevelIcons map[logrus.Level]string
+
+	// MaxWidth wraps fields that do not fit into the line anymore, 0 uses the terminal width and a negative value disables wrapping
+	MaxWidth int
+
+	startOnce sync.Once
+	start     time.Time
+
+	colorOnce sync.Once
+	useColors bool
+}
+
+func getASCIIIcon(level logrus.Level) string {
+	switch level {
+	case logrus.PanicLevel:
+		return "!!"
+	case logrus.FatalLevel:
+		return "XX"
+	case logrus.ErrorLevel:
+		return "E!"
+	case logrus.WarnLevel:
+		return "W "
+	case logrus.DebugLevel:
+		return "D "
+	case logrus.TraceLevel:
+		return "T "
+	default:
+		return "> "
+	}
+}
+
+func (f *Formatter) levelMarkup(level logrus.Level) (icon string, color int) {
+	icon, color = getLevelMarkup(level)
+	if f.ASCIIIcons {
+		icon = getASCIIIcon(level)
+	}
+	if custom, ok := f.LevelIcons[level]; ok {
+		icon = custom
+	}
+	if custom, ok := f.LevelColors[level]; ok {
+		color = custom
+	}
+	return icon, color
+}
+
+// colorsEnabled decides once per formatter if escape codes are printed, following NO_COLOR and CLICOLOR_FORCE
+func (f *Formatter) colorsEnabled(entry *logrus.Entry) bool {
+	f.colorOnce.Do(func() {
+		switch {
+		case f.DisableColors, os.Getenv("NO_COLOR") != "":
+			f.useColors = false
+		case f.ForceColors:
+			f.useColors = true
+		case os.Getenv("CLICOLOR_FORCE") != "" && os.Getenv("CLICOLOR_FORCE") != "0":
+			f.useColors = true
+		case entry.Logger != nil:
+			f.useColors = isTerminal(entry.Logger.Out)
+		}
+	})
+	return f.useColors
+}
+
+// lineWidth returns the width fields are wrapped at, 0 means no wrapping
+func (f *Formatter) lineWidth(entry *logrus.Entry) int {
+	if f.MaxWidth < 0 {
+		return 0
+	}
+	width := 0
+	if entry.Logger != nil {
+		// pipes and files are never wrapped at the terminal width, only at MaxWidth
+		if fd, ok := terminalFd(entry.Logger.Out); ok {
+			width = terminalColumns(fd)
+			if width == 0 {
+				width, _ = strconv.Atoi(os.Getenv("COLUMNS"))
+			}
+		}
+	}
+	if f.MaxWidth > 0 && (width == 0 || f.MaxWidth < width) {
+		width = f.MaxWidth
+	}
+	return width
+}
+
+// displayWidth approximates the number of terminal cells s takes, emoji take two
+func displayWidth(s string) int {
+	width := 0
+	for _, r := range s {
+		if r >= 0x1F000 {
+			width += 2
+		} else {
+			width++
+		}
+	}
+	return width
+}
+
+func isTerminal(w io.Writer) bool {
+	_, ok := terminalFd(w)
+	return ok
+}
+
+// terminalFd returns the file descriptor of w if it writes to a terminal.
+// Besides files it accepts writers exposing their descriptor with Fd(), writers wrapping another one with Unwrap() and the console writer of go-colorable
+func terminalFd(w io.Writer) (uintptr, bool) {
+	if wrapper, ok := w.(interface{ Unwrap() io.Writer }); ok {
+		return terminalFd(wrapper.Unwrap())
+	}
+	if file, ok := w.(interface{ Fd() uintptr }); ok {
+		fd := file.Fd()
+		return fd, isatty.IsTerminal(fd) || isatty.IsCygwinTerminal(fd)
+	}
+	return wrappedTerminalFd(w)
 }
 
 func getLevelMarkup(level logrus.Level) (icon string, color int) {
@@ -80,20 +243,175 @@ func getLevelMarkup(level logrus.Level) (icon string, color int) {
 	}
 }
 
+// spanIndent renders entries logged inside nested spans as a tree
+func spanIndent(entry *logrus.Entry) string {
+	depth, ok := entry.Data["span_depth"].(int)
+	if !ok || depth <= 0 {
+		return ""
+	}
+	return strings.Repeat("  ", depth-1) + "└─ "
+}
+
+func (f *Formatter) timestamp(entry *logrus.Entry) string {
+	switch f.Timestamp {
+	case TimestampAbsolute:
+		format := f.TimestampFormat
+		if format == "" {
+			format = defaultTimestampFormat
+		}
+		return entry.Time.Format(format)
+	case TimestampRelative:
+		f.startOnce.Do(func() {
+			f.start = entry.Time
+		})
+		return fmt.Sprintf("%+10.3fs", entry.Time.Sub(f.start).Seconds())
+	default:
+		return ""
+	}
+}
+
+func (f *Formatter) moduleColumn(entry *logrus.Entry) string {
+	width := f.ModuleWidth
+	if width <= 0 {
+		width = defaultModuleWidth
+	}
+	module, _ := entry.Data["module"].(string)
+	return fmt.Sprintf("%-*s", width, module)
+}
+
+// formatValue renders structured values as compact JSON and quotes values that would otherwise be ambiguous when reading key=value pairs
+func formatValue(value interface{}) string {
+	switch value.(type) {
+	case error, fmt.Stringer:
+	default:
+		if isStructured(value) {
+			if data, err := json.Marshal(value); err == nil {
+				return string(data)
+			}
+		}
+	}
+
+	s := fmt.Sprint(value)
+	if s == "" || strings.ContainsAny(s, " \t\n\"=") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+func isStructured(value interface{}) bool {
+	v := reflect.ValueOf(value)
+	if v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Map, reflect.Slice, reflect.Array, reflect.Struct:
+		return true
+	default:
+		return false
+	}
+}
+
+// splitWidth cuts s into pieces of at most width cells
+func splitWidth(s string, width int) []string {
+	if width <= 0 {
+		return []string{s}
+	}
+	parts := make([]string, 0, 1)
+	current, currentWidth := "", 0
+	for _, r := range s {
+		w := displayWidth(string(r))
+		if currentWidth+w > width && current != "" {
+			parts = append(parts, current)
+			current, currentWidth = "", 0
+		}
+		current += string(r)
+		currentWidth += w
+	}
+	return append(parts, current)
+}
+
 // Format building log message.
 func (f *Formatter) Format(entry *logrus.Entry) ([]byte, error) {
-	icon, color := getLevelMarkup(entry.Level)
-	output := ""
-	if color == 0 {
-		output = fmt.Sprintf("%s %s\t", icon, entry.Message)
-	} else {
-		output = fmt.Sprintf("%s \x1b[%dm%s\x1b[0m\t", icon, color, entry.Message)
+	icon, color := f.levelMarkup(entry.Level)
+	useColors := f.colorsEnabled(entry)
+	paint := func(color int, s string) string {
+		if !useColors || color == 0 {
+			return s
+		}
+		return fmt.Sprintf("\x1b[%dm%s\x1b[0m", color, s)
+	}
+
+	message := spanIndent(entry) + strings.TrimRight(entry.Message, "\n")
+	if duration, ok := entry.Data["duration_ms"]; ok && entry.Data["span"] != nil {
+		message = fmt.Sprintf("%s (%vms)", message, duration)
+	}
+
+	// prefix is tracked without escape codes to know where the message column starts
+	output, prefix := "", ""
+	if ts := f.timestamp(entry); ts != "" {
+		output, prefix = paint(colorGray, ts)+" ", ts+" "
+	}
+	output, prefix = output+icon+" ", prefix+icon+" "
+	if f.ModuleColumn {
+		module := f.moduleColumn(entry)
+		output, prefix = output+paint(colorGray, module)+" ", prefix+module+" "
 	}
+	indentWidth := displayWidth(prefix)
+	indent := strings.Repeat(" ", indentWidth)
 
-	for k, v := range entry.Data {
+	// continuation lines of multi line messages stay in the message column
+	lines := strings.Split(message, "\n")
+	for i, line := range lines {
+		if i > 0 {
+			output += "\n" + indent
+		}
+		output += paint(color, line)
+	}
+	output += "\t"
+	column := indentWidth + displayWidth(lines[len(lines)-1])
+	column = (column/8 + 1) * 8
+
+	keys := make([]string, 0, len(entry.Data))
+	for k := range entry.Data {
+		if f.ModuleColumn && k == "module" {
+			continue
+		}
 		if f.PrintFields || !f.DisablePrintErrors && k == "error" {
-			output = fmt.Sprintf("%s \x1b[%dm%s\x1b[0m=%v", output, color, k, v)
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	separator := f.FieldSeparator
+	if separator == "" {
+		separator = defaultFieldSeparator
+	}
+	width := f.lineWidth(entry)
+	for i, k := range keys {
+		sep := separator
+		if i == 0 {
+			sep = " "
+		}
+		value := formatValue(entry.Data[k])
+		fieldWidth := displayWidth(k) + 1 + displayWidth(value)
+		if width > 0 && column+displayWidth(sep)+fieldWidth > width && column > indentWidth {
+			// continue on the next line, below the message
+			output += "\n" + indent
+			column, sep = indentWidth, ""
+		}
+
+		output += sep + paint(color, k) + "="
+		column += displayWidth(sep) + displayWidth(k) + 1
+		if width > 0 && column+displayWidth(value) > width {
+			// the value alone is too long, wrap it aligned to its own start
+			valueIndent := strings.Repeat(" ", column)
+			parts := splitWidth(value, width-column)
+			output += strings.Join(parts, "\n"+valueIndent)
+			column += displayWidth(parts[len(parts)-1])
+			continue
 		}
+		output += value
+		column += displayWidth(value)
 	}
 
 	output = fmt.Sprintf("%s\n", output)
@@ -0,0 +1,125 @@
+package cliformatter_test
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/s00500/env_logger/cliformatter"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func format(t *testing.T, f *cliformatter.Formatter, level logrus.Level, msg string, fields logrus.Fields) string {
+	logger := logrus.New()
+	logger.Out = &bytes.Buffer{}
+	entry := &logrus.Entry{Logger: logger, Time: time.Date(2022, 1, 2, 15, 4, 5, 0, time.UTC), Level: level, Message: msg, Data: fields}
+	out, err := f.Format(entry)
+	assert.NoError(t, err)
+	return string(out)
+}
+
+func TestFormatFieldsSortedAndQuoted(t *testing.T) {
+	f := &cliformatter.Formatter{PrintFields: true, ASCIIIcons: true}
+	out := format(t, f, logrus.WarnLevel, "hello", logrus.Fields{"b": "has space", "a": 1, "c": ""})
+	assert.Equal(t, "W  hello\t a=1 b=\"has space\" c=\"\"\n", out)
+}
+
+func TestFormatModuleColumnAndTimestamp(t *testing.T) {
+	f := &cliformatter.Formatter{PrintFields: true, ASCIIIcons: true, ModuleColumn: true, ModuleWidth: 6, Timestamp: cliformatter.TimestampAbsolute, FieldSeparator: ", "}
+	out := format(t, f, logrus.InfoLevel, "hello", logrus.Fields{"module": "foo", "x": 1, "y": 2})
+	assert.Equal(t, "15:04:05.000 >  foo    hello\t x=1, y=2\n", out)
+}
+
+func TestFormatColors(t *testing.T) {
+	out := format(t, &cliformatter.Formatter{}, logrus.ErrorLevel, "boom", nil)
+	assert.NotContains(t, out, "\x1b[", "no colors when not writing to a terminal")
+
+	out = format(t, &cliformatter.Formatter{ForceColors: true, LevelIcons: map[logrus.Level]string{logrus.ErrorLevel: "!"}}, logrus.ErrorLevel, "boom", nil)
+	assert.Equal(t, "! \x1b[91mboom\x1b[0m\t\n", out)
+
+	t.Setenv("NO_COLOR", "1")
+	out = format(t, &cliformatter.Formatter{ForceColors: true}, logrus.ErrorLevel, "boom", nil)
+	assert.NotContains(t, out, "\x1b[")
+}
+
+func TestFormatMultiLineAndWrapping(t *testing.T) {
+	f := &cliformatter.Formatter{PrintFields: true, ASCIIIcons: true, MaxWidth: 30}
+	out := format(t, f, logrus.InfoLevel, "first\nsecond", logrus.Fields{"list": []int{1, 2}, "name": "a long value that wraps"})
+	assert.Equal(t, ">  first\n   second\t list=[1,2]\n   name=\"a long value that wra\n        ps\"\n", out)
+}
+
+func TestFormatTimestampModes(t *testing.T) {
+	f := &cliformatter.Formatter{ASCIIIcons: true, Timestamp: cliformatter.TimestampAbsolute, TimestampFormat: time.RFC3339}
+	assert.Equal(t, "2022-01-02T15:04:05Z >  hello\t\n", format(t, f, logrus.InfoLevel, "hello", nil))
+
+	f = &cliformatter.Formatter{ASCIIIcons: true, Timestamp: cliformatter.TimestampRelative}
+	logger := logrus.New()
+	logger.Out = &bytes.Buffer{}
+	start := time.Date(2022, 1, 2, 15, 4, 5, 0, time.UTC)
+	for i, expected := range []string{"    +0.000s >  first\t\n", "    +1.500s >  second\t\n"} {
+		entry := &logrus.Entry{Logger: logger, Time: start.Add(time.Duration(i) * 1500 * time.Millisecond), Level: logrus.InfoLevel, Message: []string{"first", "second"}[i]}
+		out, err := f.Format(entry)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, string(out))
+	}
+
+	f = &cliformatter.Formatter{ASCIIIcons: true}
+	assert.Equal(t, ">  hello\t\n", format(t, f, logrus.InfoLevel, "hello", nil))
+}
+
+func TestFormatModuleColumnDefaults(t *testing.T) {
+	f := &cliformatter.Formatter{PrintFields: true, ASCIIIcons: true, ModuleColumn: true}
+	out := format(t, f, logrus.InfoLevel, "hello", logrus.Fields{"module": "foo"})
+	assert.Equal(t, ">  foo              hello\t\n", out)
+
+	// without the column the module is a regular field
+	f = &cliformatter.Formatter{PrintFields: true, ASCIIIcons: true}
+	out = format(t, f, logrus.InfoLevel, "hello", logrus.Fields{"module": "foo", "a": 1})
+	assert.Equal(t, ">  hello\t a=1 module=foo\n", out)
+}
+
+func TestFormatFieldsSortedWithSeparator(t *testing.T) {
+	f := &cliformatter.Formatter{PrintFields: true, ASCIIIcons: true, FieldSeparator: " | "}
+	out := format(t, f, logrus.DebugLevel, "hello", logrus.Fields{"zeta": 1, "alpha": 2, "mid": 3, "Beta": 4})
+	assert.Equal(t, "D  hello\t Beta=4 | alpha=2 | mid=3 | zeta=1\n", out)
+
+	// only errors are printed without PrintFields
+	f = &cliformatter.Formatter{ASCIIIcons: true}
+	out = format(t, f, logrus.ErrorLevel, "hello", logrus.Fields{"error": "boom", "other": 1})
+	assert.Equal(t, "E! hello\t error=boom\n", out)
+}
+
+func TestFormatColumnsOnlyForTerminals(t *testing.T) {
+	t.Setenv("COLUMNS", "20")
+	f := &cliformatter.Formatter{PrintFields: true, ASCIIIcons: true}
+	out := format(t, f, logrus.InfoLevel, "hello", logrus.Fields{"name": "a long value that would wrap"})
+	assert.Equal(t, ">  hello\t name=\"a long value that would wrap\"\n", out)
+
+	// files that are no terminal, eg. a pipe, are not wrapped either
+	r, w, err := os.Pipe()
+	assert.NoError(t, err)
+	defer r.Close()
+	defer w.Close()
+	logger := logrus.New()
+	logger.Out = w
+	entry := &logrus.Entry{Logger: logger, Level: logrus.InfoLevel, Message: "hello", Data: logrus.Fields{"name": "a long value that would wrap"}}
+	formatted, err := f.Format(entry)
+	assert.NoError(t, err)
+	assert.Equal(t, ">  hello\t name=\"a long value that would wrap\"\n", string(formatted))
+}
+
+func TestFormatSpanTree(t *testing.T) {
+	f := &cliformatter.Formatter{ASCIIIcons: true}
+	assert.Equal(t, ">  startup\t\n", format(t, f, logrus.InfoLevel, "startup", logrus.Fields{"span": "startup", "span_depth": 0}))
+	assert.Equal(t, ">  └─ load config\t\n", format(t, f, logrus.InfoLevel, "load config", logrus.Fields{"span": "load config", "span_depth": 1, "parent_span": "startup"}))
+	assert.Equal(t, ">    └─ parse\t\n", format(t, f, logrus.InfoLevel, "parse", logrus.Fields{"span": "parse", "span_depth": 2, "parent_span": "load config"}))
+
+	// the end of a span shows its duration
+	end := logrus.Fields{"span": "parse", "span_depth": 2, "parent_span": "load config", "duration_ms": 12.5}
+	assert.Equal(t, ">    └─ parse (12.5ms)\t\n", format(t, f, logrus.InfoLevel, "parse", end))
+
+	// timers outside of spans keep duration_ms as field only
+	assert.Equal(t, ">  load\t\n", format(t, f, logrus.InfoLevel, "load", logrus.Fields{"duration_ms": 3}))
+}
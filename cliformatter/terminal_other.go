@@ -0,0 +1,15 @@
+//go:build !unix && !windows
+// +build !unix,!windows
+
+package cliformatter
+
+import "io"
+
+func terminalColumns(fd uintptr) int {
+	return 0
+}
+
+// wrappedTerminalFd is only needed for the console writer of go-colorable on windows
+func wrappedTerminalFd(w io.Writer) (uintptr, bool) {
+	return 0, false
+}
@@ -0,0 +1,23 @@
+//go:build unix
+// +build unix
+
+package cliformatter
+
+import (
+	"io"
+
+	"golang.org/x/sys/unix"
+)
+
+func terminalColumns(fd uintptr) int {
+	ws, err := unix.IoctlGetWinsize(int(fd), unix.TIOCGWINSZ)
+	if err != nil {
+		return 0
+	}
+	return int(ws.Col)
+}
+
+// wrappedTerminalFd is only needed for the console writer of go-colorable on windows
+func wrappedTerminalFd(w io.Writer) (uintptr, bool) {
+	return 0, false
+}
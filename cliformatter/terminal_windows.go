@@ -0,0 +1,29 @@
+//go:build windows
+// +build windows
+
+package cliformatter
+
+import (
+	"io"
+	"os"
+
+	"github.com/mattn/go-colorable"
+	"golang.org/x/sys/windows"
+)
+
+func terminalColumns(fd uintptr) int {
+	var info windows.ConsoleScreenBufferInfo
+	if err := windows.GetConsoleScreenBufferInfo(windows.Handle(fd), &info); err != nil {
+		return 0
+	}
+	return int(info.Window.Right-info.Window.Left) + 1
+}
+
+// wrappedTerminalFd recognises the console writer of go-colorable, the default output of env_logger on windows.
+// go-colorable only creates it for consoles and does not expose its handle, so the console of stdout is measured
+func wrappedTerminalFd(w io.Writer) (uintptr, bool) {
+	if _, ok := w.(*colorable.Writer); ok {
+		return os.Stdout.Fd(), true
+	}
+	return 0, false
+}
@@ -1,12 +1,46 @@
 package env_logger
 
 import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
 	"github.com/mattn/go-colorable"
 	logrus "github.com/sirupsen/logrus"
 )
 
+// GlobalModule addresses the default level in SetModuleLevel and ResetModuleLevel
+const GlobalModule = "global"
+
+// configFlags and configOptions are the tokens of the log config that are not module levels
+var configFlags = map[string]bool{"ln": true, "pp": true, "gr": true, "grl": true, "ringall": true, "ringdump": true}
+var configOptions = map[string]bool{
+	"mut": true, "blk": true, "tstats": true, "tleak": true, "ring": true,
+	"ppport": true, "ppaddr": true, "ppsock": true, "ppallow": true,
+	"grl": true, "grleak": true, "grleakmin": true,
+	"cpuprof": true, "heapprof": true, "dir": true, "profkeep": true,
+	"heapwatch": true, "grwatch": true, "watchcool": true, "trace": true,
+	"fmt": true, "out": true,
+}
+
+var levelNames = []string{"trace", "debug", "info", "warn", "warning", "error", "fatal", "panic"}
+
+// configMu serializes read-modify-write cycles on the active config
+var configMu sync.Mutex
+
+var knownModules sync.Map
+
 // SetGlobalDebugConfig overrides the debug config, but with default logger at runtime
+// Temporary overrides that are still active stay applied on top of the new config
 func SetGlobalDebugConfig(debugConfig string) {
+	configMu.Lock()
+	defer configMu.Unlock()
+	setBaseConfigLocked(debugConfig)
+}
+
+// applyDebugConfig configures all loggers with debugConfig and a fresh default logger
+func applyDebugConfig(debugConfig string) {
 	logger := logrus.New()
 
 	logger.Formatter.(*logrus.TextFormatter).EnvironmentOverrideColors = true
@@ -14,7 +48,159 @@ func SetGlobalDebugConfig(debugConfig string) {
 	ConfigureAllLoggers(logger, debugConfig)
 }
 
-// FUnction to list all modules that have logged ? kinda hard to do...
-func ListModules() {
+// DebugConfig returns the log config string that is currently active
+func DebugConfig() string {
+	loggersMu.RLock()
+	defer loggersMu.RUnlock()
+	return activeDebugConfig
+}
+
+func registerModule(module string) {
+	if _, ok := knownModules.Load(module); !ok {
+		knownModules.Store(module, struct{}{})
+	}
+}
+
+// ListModules returns all modules that have logged or requested a logger so far, plus the configured ones
+func ListModules() []string {
+	modules := make(map[string]bool)
+	knownModules.Range(func(key, _ interface{}) bool {
+		modules[key.(string)] = true
+		return true
+	})
+	loggersMu.RLock()
+	for module := range loggers {
+		if module != "global_log" {
+			modules[module] = true
+		}
+	}
+	loggersMu.RUnlock()
+
+	list := make([]string, 0, len(modules))
+	for module := range modules {
+		list = append(list, module)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// ModuleLevels returns the effective level of every known module
+func ModuleLevels() map[string]logrus.Level {
+	levels := make(map[string]logrus.Level)
+	for _, module := range ListModules() {
+		levels[module] = effectiveLevel(lookupLogger(module))
+	}
+	return levels
+}
+
+// IsModuleConfigured reports if the active config contains a level for module
+func IsModuleConfigured(module string) bool {
+	loggersMu.RLock()
+	defer loggersMu.RUnlock()
+	if module == GlobalModule {
+		module = "global_log"
+	}
+	_, ok := loggers[module]
+	return ok
+}
+
+// levelName returns the token used for level in the log config
+func levelName(level logrus.Level) string {
+	if level == logrus.WarnLevel {
+		return "warn"
+	}
+	return level.String()
+}
+
+func isLevelToken(token string) bool {
+	for _, name := range levelNames {
+		if strings.EqualFold(name, token) {
+			return true
+		}
+	}
+	return false
+}
+
+func validateModuleName(module string) error {
+	if module == "" || strings.ContainsAny(module, ",= ") {
+		return fmt.Errorf("invalid module name '%s'", module)
+	}
+	if configOptions[module] || configFlags[module] {
+		return fmt.Errorf("'%s' is a config option, not a module", module)
+	}
+	return nil
+}
+
+// withModuleLevel returns debugConfig with the level token for module replaced, an empty level removes it
+func withModuleLevel(debugConfig string, module string, level string) string {
+	tokens := make([]string, 0)
+	for _, token := range strings.Split(debugConfig, ",") {
+		if token == "" {
+			continue
+		}
+		tmp := strings.Split(token, "=")
+		if module == GlobalModule && len(tmp) == 1 && isLevelToken(tmp[0]) {
+			continue
+		}
+		if len(tmp) == 2 && tmp[0] == module {
+			continue
+		}
+		tokens = append(tokens, token)
+	}
+
+	if level != "" {
+		if module == GlobalModule {
+			tokens = append(tokens, level)
+		} else {
+			tokens = append(tokens, module+"="+level)
+		}
+	}
+	return strings.Join(tokens, ",")
+}
+
+// SetModuleLevel changes the level of a single module in the active config, use GlobalModule for the default level
+func SetModuleLevel(module string, level logrus.Level) error {
+	if err := validateModuleName(module); err != nil {
+		return err
+	}
+	configMu.Lock()
+	defer configMu.Unlock()
+	setBaseConfigLocked(withModuleLevel(baseConfigLocked(), module, levelName(level)))
+	return nil
+}
+
+// ResetModuleLevel removes the level of a single module from the active config, so it falls back to the default level again
+func ResetModuleLevel(module string) error {
+	if err := validateModuleName(module); err != nil {
+		return err
+	}
+	configMu.Lock()
+	defer configMu.Unlock()
+	setBaseConfigLocked(withModuleLevel(baseConfigLocked(), module, ""))
+	return nil
+}
+
+// withFlag returns debugConfig with flag added or removed
+func withFlag(debugConfig string, flag string, enabled bool) string {
+	tokens := make([]string, 0)
+	for _, token := range strings.Split(debugConfig, ",") {
+		if token != "" && token != flag {
+			tokens = append(tokens, token)
+		}
+	}
+	if enabled {
+		tokens = append(tokens, flag)
+	}
+	return strings.Join(tokens, ",")
+}
 
+// SetConfigFlag enables or disables a flag like ln or gr in the active config, temporary overrides stay temporary
+func SetConfigFlag(flag string, enabled bool) error {
+	if !configFlags[flag] {
+		return fmt.Errorf("unknown config flag '%s'", flag)
+	}
+	configMu.Lock()
+	defer configMu.Unlock()
+	setBaseConfigLocked(withFlag(baseConfigLocked(), flag, enabled))
+	return nil
 }
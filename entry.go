@@ -2,6 +2,11 @@ package env_logger
 
 import logrus "github.com/sirupsen/logrus"
 
+// IsLevelEnabled checks if entries at level are written, also with ringall or ringdump where the logger runs at trace level
+func (e *Entry) IsLevelEnabled(level logrus.Level) bool {
+	return effectiveLevel(getLogger(e).Logger) >= level
+}
+
 func (e *Entry) WithField(key string, value interface{}) *Entry {
 	return (*Entry)(getLogger(e).WithField(key, value))
 }
@@ -3,12 +3,14 @@ package env_logger
 import (
 	"context"
 	"fmt"
+	"net"
 	"os"
 	"runtime"
 	"runtime/debug"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"sync/atomic"
 
@@ -19,6 +21,12 @@ import (
 var (
 	defaultLogger *logrus.Logger
 	loggers       = make(map[string]*logrus.Logger)
+	loggersMu     sync.RWMutex
+
+	// activeDebugConfig is the config string the loggers are currently configured with
+	activeDebugConfig string
+	// activeProfileOutput is where profiles, dumps and traces of the current config go
+	activeProfileOutput = profileOutput{dir: os.TempDir(), keep: defaultProfileKeep}
 )
 
 // Pass through type to not have another import in packages using this lib
@@ -40,7 +48,7 @@ func toEnum(s string) int {
 	switch strings.ToLower(s) {
 	case "trace":
 		return TraceV
-	case "warn":
+	case "warn", "warning":
 		return WarnV
 	case "debug":
 		return DebugV
@@ -79,8 +87,8 @@ func configurePackageLogger(log *logrus.Logger, value int) *logrus.Logger {
 	return log
 }
 
-var filelines = false
-var printGoRoutines = false
+var filelines atomic.Bool
+var printGoRoutines atomic.Bool
 var mainModuleName = ""
 
 func init() {
@@ -102,23 +110,43 @@ func init() {
 
 // EnableLineNumbers log output of linenumbers as logerus fields
 func EnableLineNumbers() {
-	filelines = true
+	filelines.Store(true)
 }
 
 // GetLoggerForPrefix gets the logger for a certain prefix if it has been configured
 func GetLoggerForPrefix(prefix string) *Entry {
-	if logger, ok := loggers[prefix]; ok {
-		return (*Entry)(logger.WithFields(logrus.Fields{"module": prefix}))
+	return (*Entry)(loggerFor(prefix).WithFields(logrus.Fields{"module": prefix}))
+}
+
+// loggerFor returns the logger configured for module or the default logger and remembers the module
+func loggerFor(module string) *logrus.Logger {
+	registerModule(module)
+	return lookupLogger(module)
+}
+
+func lookupLogger(module string) *logrus.Logger {
+	loggersMu.RLock()
+	defer loggersMu.RUnlock()
+	if logger, ok := loggers[module]; ok {
+		return logger
 	}
-	return (*Entry)((defaultLogger.WithFields(logrus.Fields{"module": prefix})))
+	return defaultLogger
 }
 
 // SetLevel sets the default loggers level
 func SetLevel(level logrus.Level) {
-	defaultLogger.SetLevel(level)
+	loggersMu.RLock()
+	defer loggersMu.RUnlock()
+	setEffectiveLevel(defaultLogger, level)
+}
+
+// GetLevel returns the default loggers level. With ringall or ringdump the logger itself runs at trace level, this is the configured one
+func GetLevel() logrus.Level {
+	loggersMu.RLock()
+	defer loggersMu.RUnlock()
+	return effectiveLevel(defaultLogger)
 }
 
-var startServer sync.Once
 var cancelFunc *context.CancelFunc
 
 var noCustomizations atomic.Bool
@@ -132,13 +160,27 @@ func ConfigureAllLoggers(newdefaultLogger *logrus.Logger, debugConfig string) {
 	if cancelFunc != nil {
 		(*cancelFunc)()
 	}
+	// background loops live until the next reconfiguration
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelFunc = &cancel
 
 	// reset all
-	printGoRoutines = false
-	filelines = false
-
-	startProfileServer := false
-	profileServerPort := uint16(11111)
+	printGoRoutines.Store(false)
+	filelines.Store(false)
+	ungateLogger(newdefaultLogger)
+	restoreOutput(newdefaultLogger)
+	var formatter logrus.Formatter
+	outTarget := ""
+	ringSize, ringAll, ringDump := 0, false, false
+	goroutineLeakInterval, goroutineLeakGrowth := time.Duration(0), defaultGoroutineGrowth
+	profiles := profileOutput{dir: os.TempDir(), keep: defaultProfileKeep}
+	cpuProfileDuration, heapProfileInterval, blockRate := time.Duration(0), time.Duration(0), 0
+	watchdog := watchdogConfig{cooldown: defaultWatchdogCooldown}
+	traceDuration := time.Duration(0)
+
+	enableProfileServer := false
+	profileServerNetwork, profileServerAddr := "tcp", defaultProfileServerAddr
+	profileServerAllow, profileServerAllowSet := "", false
 	if debugConfig != "" {
 		packages := strings.Split(debugConfig, ",")
 
@@ -146,7 +188,7 @@ func ConfigureAllLoggers(newdefaultLogger *logrus.Logger, debugConfig string) {
 			// check if a package name has been specified, if not default to main
 			tmp := strings.Split(pkg, "=")
 			if len(tmp) == 1 && tmp[0] == "ln" {
-				filelines = true
+				filelines.Store(true)
 			} else if len(tmp) == 2 && tmp[0] == "mut" { // mut=10 to set it up
 				if val, err := strconv.Atoi(tmp[1]); err == nil {
 					runtime.SetMutexProfileFraction(val)
@@ -154,20 +196,93 @@ func ConfigureAllLoggers(newdefaultLogger *logrus.Logger, debugConfig string) {
 			} else if len(tmp) == 2 && tmp[0] == "blk" { // blk=10 to set blockProfile
 				if val, err := strconv.Atoi(tmp[1]); err == nil {
 					runtime.SetBlockProfileRate(val)
+					blockRate = val
 				}
-			} else if len(tmp) == 1 && tmp[0] == "pp" { // pprof
-				startProfileServer = true
-			} else if len(tmp) == 2 && tmp[0] == "ppport" { // pprof port
+			} else if len(tmp) == 2 && tmp[0] == "cpuprof" { // cpuprof=30s writes cpu profiles of that length to dir
+				if val, err := time.ParseDuration(tmp[1]); err == nil && val > 0 {
+					cpuProfileDuration = val
+				}
+			} else if len(tmp) == 2 && tmp[0] == "heapprof" { // heapprof=5m writes heap, mutex and block profiles to dir
+				if val, err := time.ParseDuration(tmp[1]); err == nil && val > 0 {
+					heapProfileInterval = val
+				}
+			} else if len(tmp) == 2 && tmp[0] == "dir" { // directory for profiles and dumps
+				profiles.dir = tmp[1]
+			} else if len(tmp) == 2 && tmp[0] == "profkeep" { // number of files kept per profile kind
 				if val, err := strconv.Atoi(tmp[1]); err == nil {
-					profileServerPort = uint16(val)
+					profiles.keep = val
 				}
+			} else if len(tmp) == 2 && tmp[0] == "heapwatch" { // heapwatch=1GB dumps heap and goroutines when crossed
+				if val, err := parseByteSize(tmp[1]); err == nil {
+					watchdog.heapLimit = val
+				}
+			} else if len(tmp) == 2 && tmp[0] == "grwatch" { // grwatch=5000 dumps heap and goroutines when crossed
+				if val, err := strconv.Atoi(tmp[1]); err == nil {
+					watchdog.goroutineLimit = val
+				}
+			} else if len(tmp) == 2 && tmp[0] == "trace" { // trace=5s records an execution trace to dir
+				if val, err := time.ParseDuration(tmp[1]); err == nil && val > 0 {
+					traceDuration = val
+				}
+			} else if len(tmp) == 2 && tmp[0] == "fmt" { // fmt=json|logfmt|cli|otel|ecs selects the formatter
+				if f, ok := formatterByName(tmp[1]); ok {
+					formatter = f
+				} else {
+					newdefaultLogger.Warnf("unknown formatter '%s', keeping the current one", tmp[1])
+				}
+			} else if len(tmp) >= 2 && tmp[0] == "out" { // out=gelf://host:12201 sends the entries to a log server, the url may contain =
+				outTarget = strings.TrimPrefix(pkg, "out=")
+			} else if len(tmp) == 2 && tmp[0] == "watchcool" { // minimum time between two watchdog dumps
+				if val, err := time.ParseDuration(tmp[1]); err == nil {
+					watchdog.cooldown = val
+				}
+			} else if len(tmp) == 1 && tmp[0] == "pp" { // pprof
+				enableProfileServer = true
+			} else if len(tmp) == 2 && tmp[0] == "ppport" { // pprof port, keeps the host of ppaddr
+				if val, err := strconv.Atoi(tmp[1]); err == nil && profileServerNetwork == "tcp" {
+					host, _, _ := net.SplitHostPort(profileServerAddr)
+					profileServerAddr = net.JoinHostPort(host, strconv.Itoa(int(uint16(val))))
+				}
+			} else if len(tmp) == 2 && tmp[0] == "ppaddr" { // pprof bind address, eg. 127.0.0.1:11111
+				profileServerNetwork, profileServerAddr = "tcp", tmp[1]
+			} else if len(tmp) == 2 && tmp[0] == "ppsock" { // pprof on a unix socket
+				profileServerNetwork, profileServerAddr = "unix", tmp[1]
+			} else if len(tmp) == 2 && tmp[0] == "ppallow" { // allowed client networks, eg. 127.0.0.1/32;10.0.0.0/8
+				profileServerAllow, profileServerAllowSet = tmp[1], true
 			} else if len(tmp) == 1 && tmp[0] == "gr" { // go routine log
-				printGoRoutines = true
+				printGoRoutines.Store(true)
 			} else if len(tmp) == 1 && tmp[0] == "grl" { // go routine loop
-				printGoRoutines = true
-				ctx, cancel := context.WithCancel(context.Background())
-				cancelFunc = &cancel
-				go logGoRoutines(ctx)
+				printGoRoutines.Store(true)
+				go logGoRoutines(ctx, defaultRuntimeStatsInterval)
+			} else if len(tmp) == 2 && tmp[0] == "grl" { // grl=10s go routine loop with interval
+				if val, err := time.ParseDuration(tmp[1]); err == nil && val > 0 {
+					printGoRoutines.Store(true)
+					go logGoRoutines(ctx, val)
+				}
+			} else if len(tmp) == 2 && tmp[0] == "grleak" { // grleak=30s reports goroutine groups growing since start
+				if val, err := time.ParseDuration(tmp[1]); err == nil && val > 0 {
+					goroutineLeakInterval = val
+				}
+			} else if len(tmp) == 2 && tmp[0] == "grleakmin" { // growth of a group before it is reported
+				if val, err := strconv.Atoi(tmp[1]); err == nil && val > 0 {
+					goroutineLeakGrowth = val
+				}
+			} else if len(tmp) == 2 && tmp[0] == "tstats" { // tstats=30s periodic timer statistics
+				if val, err := time.ParseDuration(tmp[1]); err == nil && val > 0 {
+					go logTimerStats(ctx, val)
+				}
+			} else if len(tmp) == 2 && tmp[0] == "tleak" { // tleak=1m report timers running longer than that
+				if val, err := time.ParseDuration(tmp[1]); err == nil && val > 0 {
+					go logTimerLeaks(ctx, val)
+				}
+			} else if len(tmp) == 2 && tmp[0] == "ring" { // ring=1000 keeps the last entries in memory
+				if val, err := strconv.Atoi(tmp[1]); err == nil && val > 0 {
+					ringSize = val
+				}
+			} else if len(tmp) == 1 && tmp[0] == "ringall" { // ring buffer also keeps entries below the configured level
+				ringAll = true
+			} else if len(tmp) == 1 && tmp[0] == "ringdump" { // write the suppressed entries of the ring buffer on errors
+				ringDump = true
 			} else if len(tmp) == 1 {
 				levels["global_log"] = toEnum(tmp[0])
 			} else if len(tmp) == 2 {
@@ -178,44 +293,85 @@ func ConfigureAllLoggers(newdefaultLogger *logrus.Logger, debugConfig string) {
 		}
 	}
 
+	if (ringAll || ringDump) && ringSize == 0 {
+		ringSize = defaultRingSize
+	}
+	configureRing(ringSize, ringDump)
+
+	if formatter != nil {
+		replaceOutput(newdefaultLogger, nil, formatter)
+	}
+	// the protocol of a network output dictates the format
+	if out, outFormatter, err := configureNetOutput(outTarget); err != nil {
+		newdefaultLogger.Warnf("could not set up log output: %v", err)
+	} else if out != nil {
+		replaceOutput(newdefaultLogger, out, outFormatter)
+	}
+
+	newLoggers := make(map[string]*logrus.Logger, len(levels))
 	for key, value := range levels {
 		// Copy some properties of the default logger
 		pLogger := logrus.New()
 		pLogger.Out = newdefaultLogger.Out
 		pLogger.Formatter = newdefaultLogger.Formatter
-		loggers[key] = configurePackageLogger(pLogger, value)
+		attachDispatchHook(pLogger)
+		newLoggers[key] = configurePackageLogger(pLogger, value)
+	}
+	attachDispatchHook(newdefaultLogger)
+	if ringAll || ringDump {
+		for _, logger := range newLoggers {
+			gateLogger(logger)
+		}
+		gateLogger(newdefaultLogger)
 	}
 
+	loggersMu.Lock()
+	// modules missing in the new config fall back to the default logger again
+	loggers = newLoggers
+	activeDebugConfig = debugConfig
+	activeProfileOutput = profiles
+
 	// configure main logger
 	if value, ok := loggers["global_log"]; ok {
 		defaultLogger = value
 	} else {
 		defaultLogger = newdefaultLogger
 	}
-	if startProfileServer {
-		startServer.Do(func() {
-			go profileServer(profileServerPort)
-		})
+	loggersMu.Unlock()
+	startConfiguredProfiles(profiles, cpuProfileDuration, heapProfileInterval, blockRate)
+	if watchdog.heapLimit > 0 || watchdog.goroutineLimit > 0 {
+		watchdog.profiles = profiles
+		go watchThresholds(ctx, watchdog)
+	}
+	if goroutineLeakInterval > 0 {
+		go watchGoroutineLeaks(ctx, goroutineLeakInterval, goroutineLeakGrowth)
 	}
-}
 
-func AutoStartProfileServer(port uint16) {
-	if port == 0 {
-		port = 11111
+	startConfiguredTrace(traceDuration)
+
+	// a config without ppallow must not open up the admin endpoints, eg. a level change via /logstring. Only ppallow= clears the list
+	if !profileServerAllowSet {
+		profileServerAllow = adminAllowList()
+	}
+	configureAdminAuth(profileServerAllow)
+	if enableProfileServer {
+		startProfileServer(profileServerNetwork, profileServerAddr)
 	}
-	startServer.Do(func() {
-		go profileServer(port)
-	})
 }
 
-// Props to https://stackoverflow.com/a/35213181 for the code
+// getPackage resolves the module, file and line of whoever called the function calling getLogger()
 func getPackage() (string, string, int) {
+	return getCaller(5)
+}
+
+// Props to https://stackoverflow.com/a/35213181 for the code
+func getCaller(skip int) (string, string, int) {
 
 	// we get the callers as uintptrs - but we just need 1
 	fpcs := make([]uintptr, 1)
 
-	// skip 4 levels to get to the caller of whoever called getPackage()
-	n := runtime.Callers(4, fpcs)
+	// skip levels to get to the caller we are interested in, 0 is runtime.Callers itself
+	n := runtime.Callers(skip, fpcs)
 	if n == 0 {
 		return "", "", 0 // proper error her would be better
 	}
@@ -260,18 +416,14 @@ func getLogger(e *Entry) *logrus.Entry {
 	if e != nil {
 		logentry = (*logrus.Entry)(e)
 	} else {
-		if log, ok := loggers[pkg]; ok {
-			logentry = log.WithFields(logrus.Fields{"module": pkg})
-		} else {
-			logentry = defaultLogger.WithFields(logrus.Fields{"module": pkg})
-		}
+		logentry = loggerFor(pkg).WithFields(logrus.Fields{"module": pkg})
 	}
 
-	if filelines {
+	if filelines.Load() {
 		logentry = logentry.WithFields(logrus.Fields{"file": fmt.Sprintf("'%s:%d'", file, line)})
 	}
 
-	if printGoRoutines {
+	if printGoRoutines.Load() {
 		logentry = logentry.WithFields(logrus.Fields{"routines": runtime.NumGoroutine()})
 	}
 
@@ -1,12 +1,27 @@
 package env_logger_test
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"runtime/pprof"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	env_logger "github.com/s00500/env_logger"
 	. "github.com/s00500/env_logger/internal/testutils"
 	logrus "github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestPrint(t *testing.T) {
@@ -66,6 +81,411 @@ func TestInfolnShouldAddSpacesBetweenStrings(t *testing.T) {
 	})
 }
 
+func TestTextValuesWithSeparators(t *testing.T) {
+	LogAndAssertText(t, func(log *env_logger.Entry) {
+		log.WithField("query", "a=b c").Warn("two words")
+	}, func(fields map[string]string) {
+		assert.Equal(t, "two words", fields["msg"])
+		assert.Equal(t, "warning", fields["level"])
+		assert.Equal(t, "a=b c", fields["query"])
+		assert.Equal(t, "Testing", fields["module"])
+	})
+}
+
+func TestShouldUsesErrorClassifier(t *testing.T) {
+	defer env_logger.ResetErrorClassifiers()
+	env_logger.RegisterErrorClassifier(env_logger.ClassifyAs(context.Canceled, logrus.WarnLevel))
+
+	LogAndAssertJSON(t, func(log *env_logger.Entry) {
+		log.Should(fmt.Errorf("request aborted: %w", context.Canceled))
+	}, func(fields logrus.Fields) {
+		assert.Equal(t, "warning", fields["level"])
+	})
+
+	LogAndAssertJSON(t, func(log *env_logger.Entry) {
+		log.Should(env_logger.WithCode(io.ErrUnexpectedEOF, "E42"))
+	}, func(fields logrus.Fields) {
+		assert.Equal(t, "error", fields["level"])
+		assert.Equal(t, "E42", fields["error_code"])
+	})
+}
+
+func TestClassifierFieldsNotModified(t *testing.T) {
+	defer env_logger.ResetErrorClassifiers()
+	shared := env_logger.Fields{"component": "db"}
+	env_logger.RegisterErrorClassifier(func(err error) (logrus.Level, env_logger.Fields, bool) {
+		return logrus.WarnLevel, shared, true
+	})
+
+	for _, code := range []string{"E1", "E2"} {
+		LogAndAssertJSON(t, func(log *env_logger.Entry) {
+			log.Should(env_logger.WithCode(io.EOF, code))
+		}, func(fields logrus.Fields) {
+			assert.Equal(t, code, fields["error_code"])
+			assert.Equal(t, "db", fields["component"])
+		})
+	}
+	assert.Equal(t, env_logger.Fields{"component": "db"}, shared)
+}
+
+func TestShouldIgnoresClassifiedError(t *testing.T) {
+	defer env_logger.ResetErrorClassifiers()
+	env_logger.RegisterErrorClassifier(env_logger.ClassifyAs(io.EOF, env_logger.IgnoreLevel))
+
+	var buffer bytes.Buffer
+	logger := logrus.New()
+	logger.Out = &buffer
+	env_logger.ConfigureAllLoggers(logger, "info")
+
+	assert.True(t, env_logger.Should(io.EOF))
+	assert.True(t, env_logger.ShouldWarn(io.EOF))
+	assert.Empty(t, buffer.String())
+}
+
+func TestScopedTimer(t *testing.T) {
+	LogAndAssertJSON(t, func(log *env_logger.Entry) {
+		log.TimeScope("load config", env_logger.TimeLevel(logrus.WarnLevel))()
+	}, func(fields logrus.Fields) {
+		assert.Equal(t, "load config", fields["msg"])
+		assert.Equal(t, "warning", fields["level"])
+		assert.Contains(t, fields, "duration_ms")
+	})
+
+	var buffer bytes.Buffer
+	logger := logrus.New()
+	logger.Out = &buffer
+	env_logger.ConfigureAllLoggers(logger, "info")
+
+	env_logger.Time("fast", env_logger.TimeThreshold(time.Hour))()
+	assert.Empty(t, buffer.String())
+}
+
+func TestTimerStats(t *testing.T) {
+	env_logger.ResetTimerStats()
+	for i := 0; i < 10; i++ {
+		env_logger.Timer("stats")
+		_, err := env_logger.TimerEndValue("stats")
+		assert.NoError(t, err)
+	}
+
+	stats, ok := env_logger.TimerStats()["stats"]
+	assert.True(t, ok)
+	assert.Equal(t, uint64(10), stats.Count)
+	assert.LessOrEqual(t, stats.Min, stats.P50)
+	assert.LessOrEqual(t, stats.P50, stats.P99)
+	assert.LessOrEqual(t, stats.P99, stats.Max)
+}
+
+func TestTimerStatsCapped(t *testing.T) {
+	env_logger.ResetTimerStats()
+	defer env_logger.ResetTimerStats()
+	// the first 1000 names get their own statistics
+	for i := 0; i < 1010; i++ {
+		name := fmt.Sprintf("request-%d", i)
+		env_logger.Timer(name)
+		env_logger.TimerEndValue(name)
+	}
+
+	stats := env_logger.TimerStats()
+	assert.Len(t, stats, 1001)
+	assert.Equal(t, uint64(10), stats["(other)"].Count)
+	assert.NotContains(t, stats, "request-1005")
+}
+
+func TestTimerLeaks(t *testing.T) {
+	env_logger.Timer("leaking")
+	defer env_logger.TimerEnd("leaking")
+
+	var leak *env_logger.TimerLeak
+	leaks := env_logger.TimerLeaks(0)
+	for i := range leaks {
+		if leaks[i].Key == "leaking" {
+			leak = &leaks[i]
+		}
+	}
+	if assert.NotNil(t, leak) {
+		assert.Contains(t, leak.File, "env_logger_test.go")
+	}
+	assert.Empty(t, env_logger.TimerLeaks(time.Hour))
+}
+
+func TestNestedSpans(t *testing.T) {
+	LogAndAssertJSON(t, func(log *env_logger.Entry) {
+		// the threshold keeps End from adding a second line to the buffer
+		ctx, parent := log.StartSpan(context.Background(), "startup", env_logger.TimeThreshold(time.Hour))
+		defer parent.End()
+		ctx, child := env_logger.StartSpan(ctx, "load config", env_logger.TimeThreshold(time.Hour))
+		defer child.End()
+
+		env_logger.WithContext(ctx).Info("inside")
+	}, func(fields logrus.Fields) {
+		assert.Equal(t, "inside", fields["msg"])
+		assert.Equal(t, "load config", fields["span"])
+		assert.Equal(t, "startup", fields["parent_span"])
+		assert.Equal(t, float64(1), fields["span_depth"])
+	})
+}
+
+func TestSetModuleLevel(t *testing.T) {
+	env_logger.SetGlobalDebugConfig("ln,foo=warn,debug")
+	defer env_logger.SetGlobalDebugConfig("")
+
+	assert.NoError(t, env_logger.SetModuleLevel("foo", logrus.TraceLevel))
+	assert.NoError(t, env_logger.SetModuleLevel("bar", logrus.ErrorLevel))
+	assert.Equal(t, "ln,debug,foo=trace,bar=error", env_logger.DebugConfig())
+	assert.Equal(t, logrus.TraceLevel, env_logger.ModuleLevels()["foo"])
+
+	assert.NoError(t, env_logger.ResetModuleLevel("foo"))
+	assert.NoError(t, env_logger.ResetModuleLevel(env_logger.GlobalModule))
+	assert.Equal(t, "ln,bar=error", env_logger.DebugConfig())
+	assert.False(t, env_logger.IsModuleConfigured("foo"))
+
+	assert.Error(t, env_logger.SetModuleLevel("mut", logrus.InfoLevel))
+}
+
+func TestSetModuleLevelFor(t *testing.T) {
+	env_logger.SetGlobalDebugConfig("foo=warn")
+	defer env_logger.SetGlobalDebugConfig("")
+
+	assert.NoError(t, env_logger.SetModuleLevelFor("foo", logrus.TraceLevel, 50*time.Millisecond))
+	assert.NoError(t, env_logger.SetModuleLevelFor("bar", logrus.DebugLevel, time.Hour))
+	assert.Equal(t, "foo=trace,bar=debug", env_logger.DebugConfig())
+
+	// permanent changes stay below the active overrides
+	assert.NoError(t, env_logger.SetModuleLevel("baz", logrus.ErrorLevel))
+	assert.Equal(t, "baz=error,foo=trace,bar=debug", env_logger.DebugConfig())
+
+	assert.Eventually(t, func() bool {
+		return env_logger.DebugConfig() == "foo=warn,baz=error,bar=debug"
+	}, time.Second, 10*time.Millisecond)
+
+	env_logger.ResetLevelOverrides()
+	assert.Equal(t, "foo=warn,baz=error", env_logger.DebugConfig())
+}
+
+func TestFlagEndpointKeepsOverridesTemporary(t *testing.T) {
+	env_logger.SetGlobalDebugConfig("foo=warn")
+	defer env_logger.SetGlobalDebugConfig("")
+	assert.NoError(t, env_logger.SetModuleLevelFor("foo", logrus.TraceLevel, time.Hour))
+	handler := env_logger.AdminHandler()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/flags/ln", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "ln,foo=trace", env_logger.DebugConfig())
+
+	env_logger.ResetLevelOverrides()
+	assert.Equal(t, "foo=warn,ln", env_logger.DebugConfig())
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/flags/ln", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "foo=warn", env_logger.DebugConfig())
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/flags/foo", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestRingBufferDumpsOnError(t *testing.T) {
+	var buffer bytes.Buffer
+	logger := logrus.New()
+	logger.Out = &buffer
+	logger.Formatter = &logrus.TextFormatter{DisableColors: true}
+	env_logger.ConfigureAllLoggers(logger, "info,ring=10,ringdump")
+	defer env_logger.ConfigureAllLoggers(logger, "info")
+
+	log := env_logger.GetLoggerForPrefix("Testing")
+	log.Debug("connecting")
+	log.Info("visible")
+	assert.NotContains(t, buffer.String(), "connecting")
+
+	recent := env_logger.RecentLogs()
+	if assert.Len(t, recent, 2) {
+		assert.Equal(t, "connecting", recent[0].Message)
+		assert.Equal(t, "Testing", recent[0].Module)
+	}
+
+	log.Error("boom")
+	output := buffer.String()
+	assert.Contains(t, output, "connecting")
+	assert.Less(t, strings.Index(output, "connecting"), strings.Index(output, "boom"))
+}
+
+// overlapWriter records whether two writes ever ran at the same time
+type overlapWriter struct {
+	active     int32
+	overlapped int32
+	lines      int32
+	empty      int32
+}
+
+func (w *overlapWriter) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		atomic.AddInt32(&w.empty, 1)
+	}
+	if atomic.AddInt32(&w.active, 1) > 1 {
+		atomic.StoreInt32(&w.overlapped, 1)
+	}
+	time.Sleep(10 * time.Microsecond)
+	atomic.AddInt32(&w.lines, int32(bytes.Count(p, []byte("\n"))))
+	atomic.AddInt32(&w.active, -1)
+	return len(p), nil
+}
+
+func TestRingDumpDoesNotInterleave(t *testing.T) {
+	out := &overlapWriter{}
+	logger := logrus.New()
+	logger.Out = out
+	env_logger.ConfigureAllLoggers(logger, "info,ringdump")
+	defer env_logger.ConfigureAllLoggers(logrus.New(), "info")
+
+	log := env_logger.GetLoggerForPrefix("Testing")
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				log.Debug("context")
+				log.Info("visible")
+				env_logger.SetLevel(logrus.InfoLevel)
+			}
+		}()
+	}
+	for j := 0; j < 20; j++ {
+		log.Error("boom")
+	}
+	wg.Wait()
+	// dumps the debug entries logged after the last error
+	log.Error("boom")
+
+	assert.Zero(t, atomic.LoadInt32(&out.overlapped))
+	assert.Zero(t, atomic.LoadInt32(&out.empty))
+	// every debug entry is dumped exactly once, next to the 200 info and 21 error entries
+	assert.Equal(t, int32(200+200+21), atomic.LoadInt32(&out.lines))
+	assert.False(t, log.IsLevelEnabled(logrus.DebugLevel))
+	assert.True(t, log.IsLevelEnabled(logrus.InfoLevel))
+	assert.Equal(t, logrus.InfoLevel, env_logger.GetLevel())
+}
+
+func TestFormatterSelectedByConfig(t *testing.T) {
+	var buffer bytes.Buffer
+	logger := logrus.New()
+	logger.Out = &buffer
+	env_logger.ConfigureAllLoggers(logger, "info,fmt=otel")
+	defer env_logger.ConfigureAllLoggers(logger, "info")
+
+	env_logger.GetLoggerForPrefix("Testing").WithField("user", "ada").Info("logged in")
+
+	var fields map[string]interface{}
+	require.NoError(t, json.Unmarshal(buffer.Bytes(), &fields))
+	assert.Equal(t, "logged in", fields["body"])
+	assert.Equal(t, "INFO", fields["severity_text"])
+	assert.Equal(t, map[string]interface{}{"name": "Testing"}, fields["instrumentation_scope"])
+
+	// removing the token brings back the formatter of the caller
+	env_logger.ConfigureAllLoggers(logger, "info")
+	assert.IsType(t, &logrus.TextFormatter{}, logger.Formatter)
+	env_logger.ConfigureAllLoggers(logger, "info,fmt=nope")
+	assert.IsType(t, &logrus.TextFormatter{}, logger.Formatter)
+}
+
+func TestNetworkOutputSelectedByConfig(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	logger := logrus.New()
+	env_logger.ConfigureAllLoggers(logger, "info,out=syslog+udp://"+conn.LocalAddr().String()+"?facility=local0")
+	defer env_logger.ConfigureAllLoggers(logger, "info")
+
+	env_logger.GetLoggerForPrefix("Testing").Warn("shipped")
+
+	buf := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(buf[:n]), "<132>1 "), string(buf[:n]))
+	assert.Contains(t, string(buf[:n]), " Testing - shipped")
+
+	// without out= the logger writes to its own output again
+	env_logger.ConfigureAllLoggers(logger, "info")
+	assert.Equal(t, os.Stderr, logger.Out)
+	assert.IsType(t, &logrus.TextFormatter{}, logger.Formatter)
+}
+
+func TestNetworkOutputFlushedOnFatal(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	exitCode := -1
+	logger := logrus.New()
+	logger.ExitFunc = func(code int) { exitCode = code }
+	// no global level, so the default logger with the exit func above is used
+	env_logger.ConfigureAllLoggers(logger, "out=syslog+udp://"+conn.LocalAddr().String())
+	defer env_logger.ConfigureAllLoggers(logger, "info")
+
+	env_logger.GetLoggerForPrefix("Testing").Fatal("last words")
+	assert.Equal(t, 1, exitCode)
+
+	// the exit handler waited until the entry was sent
+	buf := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	n, _, err := conn.ReadFrom(buf)
+	require.NoError(t, err)
+	assert.Contains(t, string(buf[:n]), "last words")
+}
+
+func TestAdminHandlerMounted(t *testing.T) {
+	env_logger.SetGlobalDebugConfig("info")
+	defer env_logger.SetGlobalDebugConfig("")
+
+	mux := http.NewServeMux()
+	mux.Handle("/debug/log/", http.StripPrefix("/debug/log", env_logger.AdminHandler()))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	req, err := http.NewRequest(http.MethodPut, server.URL+"/debug/log/loglevels/foo", strings.NewReader(`{"level":"debug"}`))
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, err = http.Get(server.URL + "/debug/log/loglevels")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	var levels struct {
+		Config  string
+		Modules map[string]struct {
+			Level      string
+			Configured bool
+		}
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&levels))
+	assert.Equal(t, "info,foo=debug", levels.Config)
+	assert.Equal(t, "debug", levels.Modules["foo"].Level)
+	assert.True(t, levels.Modules["foo"].Configured)
+
+	ui, err := http.Get(server.URL + "/debug/log/")
+	require.NoError(t, err)
+	defer ui.Body.Close()
+	assert.Equal(t, http.StatusOK, ui.StatusCode)
+	assert.Contains(t, ui.Header.Get("Content-Type"), "text/html")
+}
+
+func TestDoSetsProfilerLabels(t *testing.T) {
+	log := env_logger.GetLoggerForPrefix("Testing").WithField("tenant", "acme")
+	log.Do(context.Background(), func(ctx context.Context) {
+		tenant, _ := pprof.Label(ctx, "tenant")
+		module, _ := pprof.Label(ctx, "module")
+		assert.Equal(t, "acme", tenant)
+		assert.Equal(t, "Testing", module)
+	})
+}
+
 /*
 
 // TestReportCaller verifies that when ReportCaller is set, the 'func' field
@@ -1,5 +1,108 @@
 package env_logger
 
+import (
+	"errors"
+	"sync"
+
+	logrus "github.com/sirupsen/logrus"
+)
+
+// IgnoreLevel can be returned by an ErrorClassifier to drop an error silently in Should and ShouldWarn
+const IgnoreLevel logrus.Level = 1<<32 - 1
+
+// ErrorClassifier decides how an error passed to Should or ShouldWarn gets logged.
+// It returns the level to log at, additional fields and true if it handled the error.
+type ErrorClassifier func(err error) (logrus.Level, Fields, bool)
+
+// ErrorCoder is implemented by errors that carry a machine readable code, it is logged as error_code
+type ErrorCoder interface {
+	ErrorCode() string
+}
+
+var errorClassifiers []ErrorClassifier
+var errorClassifiersMu sync.RWMutex
+
+// RegisterErrorClassifier adds a classifier that is consulted by Should and ShouldWarn, the first classifier handling an error wins
+func RegisterErrorClassifier(classifier ErrorClassifier) {
+	errorClassifiersMu.Lock()
+	defer errorClassifiersMu.Unlock()
+	errorClassifiers = append(errorClassifiers, classifier)
+}
+
+// ResetErrorClassifiers removes all registered classifiers
+func ResetErrorClassifiers() {
+	errorClassifiersMu.Lock()
+	defer errorClassifiersMu.Unlock()
+	errorClassifiers = nil
+}
+
+// ClassifyAs returns a classifier that logs every error matching target (using errors.Is) at the given level
+func ClassifyAs(target error, level logrus.Level) ErrorClassifier {
+	return func(err error) (logrus.Level, Fields, bool) {
+		if errors.Is(err, target) {
+			return level, nil, true
+		}
+		return 0, nil, false
+	}
+}
+
+type codedError struct {
+	err  error
+	code string
+}
+
+func (c *codedError) Error() string     { return c.err.Error() }
+func (c *codedError) Unwrap() error     { return c.err }
+func (c *codedError) ErrorCode() string { return c.code }
+
+// WithCode attaches an error code to an error, Should and ShouldWarn log it as error_code
+func WithCode(err error, code string) error {
+	if err == nil {
+		return nil
+	}
+	return &codedError{err: err, code: code}
+}
+
+// classifyError runs the registered classifiers and returns the level and fields to log err with
+func classifyError(err error, level logrus.Level) (logrus.Level, Fields) {
+	var fields Fields
+
+	errorClassifiersMu.RLock()
+	for _, classifier := range errorClassifiers {
+		if l, f, ok := classifier(err); ok {
+			level, fields = l, f
+			break
+		}
+	}
+	errorClassifiersMu.RUnlock()
+
+	var coder ErrorCoder
+	if errors.As(err, &coder) {
+		if _, ok := fields["error_code"]; !ok {
+			// copy, classifiers may return a map they share between calls
+			withCode := make(Fields, len(fields)+1)
+			for k, v := range fields {
+				withCode[k] = v
+			}
+			withCode["error_code"] = coder.ErrorCode()
+			fields = withCode
+		}
+	}
+	return level, fields
+}
+
+// logClassified logs err on the given logger after it went through the classifiers
+func logClassified(logger *logrus.Entry, err error, level logrus.Level) {
+	level, fields := classifyError(err, level)
+	if level == IgnoreLevel {
+		return
+	}
+	if len(fields) != 0 {
+		logger = logger.WithFields(logrus.Fields(fields))
+	}
+	logger.Log(level, err)
+}
+
 // Must Checks if an error occured, otherwise panic
 func Must(err error) {
 	if err != nil {
@@ -17,7 +120,7 @@ func MustFatal(err error) {
 // Should Checks if an error occured, otherwise prints it as error, returns true if error is not nil
 func Should(err error) bool {
 	if err != nil {
-		getLogger(nil).Error(err)
+		logClassified(getLogger(nil), err, logrus.ErrorLevel)
 		return true
 	}
 	return false
@@ -26,7 +129,7 @@ func Should(err error) bool {
 // Should Checks if an error occured, otherwise prints it as error, returns true if error is not nil
 func ShouldWrap(err error, msg string, args ...interface{}) bool {
 	if err != nil {
-		getLogger(nil).Error(Wrap(err, msg, args...))
+		logClassified(getLogger(nil), Wrap(err, msg, args...), logrus.ErrorLevel)
 		return true
 	}
 	return false
@@ -35,7 +138,7 @@ func ShouldWrap(err error, msg string, args ...interface{}) bool {
 // ShouldWarn Checks if an error occured, otherwise prints it as warning, returns true if error is not nil
 func ShouldWarn(err error) bool {
 	if err != nil {
-		getLogger(nil).Warn(err)
+		logClassified(getLogger(nil), err, logrus.WarnLevel)
 		return true
 	}
 	return false
@@ -58,7 +161,7 @@ func (e *Entry) MustFatal(err error) {
 // ShouldWrap Checks if an error occured, otherwise prints it as error, returns true if error is not nil
 func (e *Entry) ShouldWrap(err error, msg string, args ...interface{}) bool {
 	if err != nil {
-		getLogger(e).Error(Wrap(err, msg, args...))
+		logClassified(getLogger(e), Wrap(err, msg, args...), logrus.ErrorLevel)
 		return true
 	}
 	return false
@@ -68,7 +171,7 @@ func (e *Entry) ShouldWrap(err error, msg string, args ...interface{}) bool {
 func (e *Entry) Should(err error) bool {
 	if err != nil {
 		// Should get the linenumbers and goroutines!
-		getLogger(e).Error(err)
+		logClassified(getLogger(e), err, logrus.ErrorLevel)
 		return true
 	}
 	return false
@@ -77,7 +180,7 @@ func (e *Entry) Should(err error) bool {
 // ShouldWarn Checks if an error occured, otherwise prints it as warning, returns true if error is not nil
 func (e *Entry) ShouldWarn(err error) bool {
 	if err != nil {
-		getLogger(e).Warn(err)
+		logClassified(getLogger(e), err, logrus.WarnLevel)
 		return true
 	}
 	return false
@@ -0,0 +1,60 @@
+package env_logger
+
+import (
+	"io"
+
+	"github.com/s00500/env_logger/cliformatter"
+	"github.com/s00500/env_logger/jsonformatter"
+	"github.com/s00500/env_logger/logfmt"
+	logrus "github.com/sirupsen/logrus"
+)
+
+// formatterByName returns a new formatter for the fmt= token of the log config
+func formatterByName(name string) (logrus.Formatter, bool) {
+	switch name {
+	case "text":
+		return &logrus.TextFormatter{EnvironmentOverrideColors: true}, true
+	case "json":
+		return &logrus.JSONFormatter{}, true
+	case "logfmt":
+		return &logfmt.Formatter{}, true
+	case "cli":
+		return &cliformatter.Formatter{PrintFields: true}, true
+	case "otel":
+		return &jsonformatter.Formatter{Schema: jsonformatter.SchemaOTel}, true
+	case "ecs":
+		return &jsonformatter.Formatter{Schema: jsonformatter.SchemaECS}, true
+	default:
+		return nil, false
+	}
+}
+
+// replacedOutput remembers the output and formatter fmt= and out= replaced on the logger passed to ConfigureAllLoggers,
+// so they are put back once the tokens are removed again
+var replacedOutput struct {
+	logger    *logrus.Logger
+	out       io.Writer
+	formatter logrus.Formatter
+}
+
+// restoreOutput undoes fmt= and out= of the previous config on logger
+func restoreOutput(logger *logrus.Logger) {
+	if replacedOutput.logger == logger {
+		logger.SetOutput(replacedOutput.out)
+		logger.SetFormatter(replacedOutput.formatter)
+	}
+	replacedOutput.logger, replacedOutput.out, replacedOutput.formatter = nil, nil, nil
+}
+
+// replaceOutput sets out and formatter on logger, nil keeps the current one. The originals are remembered by the first call
+func replaceOutput(logger *logrus.Logger, out io.Writer, formatter logrus.Formatter) {
+	if replacedOutput.logger != logger {
+		replacedOutput.logger, replacedOutput.out, replacedOutput.formatter = logger, logger.Out, logger.Formatter
+	}
+	if out != nil {
+		logger.SetOutput(out)
+	}
+	if formatter != nil {
+		logger.SetFormatter(formatter)
+	}
+}
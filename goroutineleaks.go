@@ -0,0 +1,126 @@
+package env_logger
+
+import (
+	"context"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	logrus "github.com/sirupsen/logrus"
+)
+
+// defaultGoroutineGrowth is the growth of a group that gets reported when grleakmin is not set
+const defaultGoroutineGrowth = 10
+
+var stackOffset = regexp.MustCompile(` \+0x[0-9a-f]+$`)
+
+// goroutineStacks returns the stacks of all goroutines
+func goroutineStacks() string {
+	buf := make([]byte, 64*1024)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return string(buf[:n])
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
+
+// groupGoroutines counts goroutines by the place they were created at
+func groupGoroutines(stacks string) map[string]int {
+	groups := make(map[string]int)
+	for _, stack := range strings.Split(stacks, "\n\n") {
+		if site := creationSite(stack); site != "" {
+			groups[site]++
+		}
+	}
+	return groups
+}
+
+// creationSite returns "function file:line" of the go statement that started the goroutine
+func creationSite(stack string) string {
+	lines := strings.Split(strings.TrimSpace(stack), "\n")
+	if len(lines) < 2 || !strings.HasPrefix(lines[0], "goroutine ") {
+		return ""
+	}
+	for i, line := range lines {
+		if strings.HasPrefix(line, "created by ") && i+1 < len(lines) {
+			function := strings.TrimPrefix(line, "created by ")
+			// newer runtimes append " in goroutine N" which differs for every parent
+			if idx := strings.Index(function, " in goroutine "); idx != -1 {
+				function = function[:idx]
+			}
+			return function + " " + stackOffset.ReplaceAllString(strings.TrimSpace(lines[i+1]), "")
+		}
+	}
+	// no creator, this is the main goroutine or one started by the runtime, group by the top frame without arguments
+	function := strings.TrimSpace(lines[1])
+	if idx := strings.LastIndex(function, "("); idx > 0 && strings.HasSuffix(function, ")") {
+		function = function[:idx]
+	}
+	return function
+}
+
+// goroutineBaseline is taken when grleak is enabled the first time. It outlives the watcher, which restarts on every
+// reconfiguration, so level changes do not forget the growth seen so far. All three are guarded by goroutineLeakMu
+var goroutineBaseline map[string]int
+var goroutineReported = make(map[string]int)
+var goroutineLeakMu sync.Mutex
+
+// watchGoroutineLeaks compares goroutine groups against the baseline and reports groups that grew by threshold
+func watchGoroutineLeaks(ctx context.Context, interval time.Duration, threshold int) {
+	goroutineLeakMu.Lock()
+	if goroutineBaseline == nil {
+		goroutineBaseline = groupGoroutines(goroutineStacks())
+	}
+	goroutineLeakMu.Unlock()
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			log := GetLoggerForPrefix("goroutines")
+			for _, fields := range checkGoroutineLeaks(groupGoroutines(goroutineStacks()), threshold) {
+				log.WithFields(fields).Warn("goroutine group keeps growing, possible leak")
+			}
+		}
+	}
+}
+
+// checkGoroutineLeaks returns the groups that grew by threshold since the baseline or since they were reported last
+func checkGoroutineLeaks(current map[string]int, threshold int) []logrus.Fields {
+	goroutineLeakMu.Lock()
+	defer goroutineLeakMu.Unlock()
+
+	sites := make([]string, 0, len(current))
+	for site := range current {
+		sites = append(sites, site)
+	}
+	sort.Strings(sites)
+
+	leaks := make([]logrus.Fields, 0)
+	for _, site := range sites {
+		count := current[site]
+		last := goroutineBaseline[site]
+		if goroutineReported[site] > last {
+			last = goroutineReported[site]
+		}
+		if count-last < threshold {
+			continue
+		}
+		goroutineReported[site] = count
+		leaks = append(leaks, logrus.Fields{
+			"site":     site,
+			"baseline": goroutineBaseline[site],
+			"current":  count,
+			"growth":   count - goroutineBaseline[site],
+		})
+	}
+	return leaks
+}
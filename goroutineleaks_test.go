@@ -0,0 +1,73 @@
+package env_logger
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	logrus "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+const cannedStacks = `goroutine 1 [running]:
+main.main()
+	/app/main.go:10 +0x1d
+
+goroutine 7 [chan receive]:
+example.com/app/worker.loop(0xc000010000)
+	/app/worker/worker.go:20 +0x45
+created by example.com/app/worker.Start in goroutine 1
+	/app/worker/worker.go:12 +0x7a
+
+goroutine 8 [chan receive]:
+example.com/app/worker.loop(0xc000010010)
+	/app/worker/worker.go:20 +0x45
+created by example.com/app/worker.Start in goroutine 6
+	/app/worker/worker.go:12 +0x7a
+
+goroutine 9 [select]:
+net/http.(*persistConn).readLoop(0xc0001b2000)
+	/usr/local/go/src/net/http/transport.go:2205 +0xd85
+created by net/http.(*Transport).dialConn
+	/usr/local/go/src/net/http/transport.go:1791 +0x1b2e
+`
+
+func TestCreationSite(t *testing.T) {
+	assert.Equal(t, "main.main", creationSite("goroutine 1 [running]:\nmain.main()\n\t/app/main.go:10 +0x1d"))
+	assert.Equal(t, "example.com/app/worker.Start /app/worker/worker.go:12",
+		creationSite("goroutine 7 [chan receive]:\nexample.com/app/worker.loop(0xc000010000)\n\t/app/worker/worker.go:20 +0x45\ncreated by example.com/app/worker.Start in goroutine 1\n\t/app/worker/worker.go:12 +0x7a"))
+	assert.Equal(t, "", creationSite("not a stack"))
+}
+
+func TestGroupGoroutines(t *testing.T) {
+	assert.Equal(t, map[string]int{
+		"main.main": 1,
+		"example.com/app/worker.Start /app/worker/worker.go:12":                       2,
+		"net/http.(*Transport).dialConn /usr/local/go/src/net/http/transport.go:1791": 1,
+	}, groupGoroutines(cannedStacks))
+}
+
+func TestCheckGoroutineLeaksKeepsBaseline(t *testing.T) {
+	goroutineLeakMu.Lock()
+	savedBaseline, savedReported := goroutineBaseline, goroutineReported
+	goroutineBaseline, goroutineReported = map[string]int{"worker": 2}, make(map[string]int)
+	goroutineLeakMu.Unlock()
+	defer func() {
+		goroutineLeakMu.Lock()
+		goroutineBaseline, goroutineReported = savedBaseline, savedReported
+		goroutineLeakMu.Unlock()
+	}()
+
+	assert.Empty(t, checkGoroutineLeaks(map[string]int{"worker": 11}, 10))
+	leaks := checkGoroutineLeaks(map[string]int{"worker": 12}, 10)
+	assert.Equal(t, []logrus.Fields{{"site": "worker", "baseline": 2, "current": 12, "growth": 10}}, leaks)
+	// reported once, until it grew by threshold again
+	assert.Empty(t, checkGoroutineLeaks(map[string]int{"worker": 15}, 10))
+
+	// a restarted watcher keeps the baseline
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	watchGoroutineLeaks(ctx, time.Hour, 10)
+	leaks = checkGoroutineLeaks(map[string]int{"worker": 22}, 10)
+	assert.Equal(t, []logrus.Fields{{"site": "worker", "baseline": 2, "current": 22, "growth": 20}}, leaks)
+}
@@ -0,0 +1,103 @@
+package env_logger
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	logrus "github.com/sirupsen/logrus"
+)
+
+// LogRecord is a snapshot of a log entry as handed to the in-process consumers like the tail endpoint
+type LogRecord struct {
+	Time    time.Time              `json:"time"`
+	Level   string                 `json:"level"`
+	Module  string                 `json:"module,omitempty"`
+	Message string                 `json:"msg"`
+	Fields  map[string]interface{} `json:"fields,omitempty"`
+
+	level      logrus.Level
+	logger     *logrus.Logger
+	suppressed bool // below the configured level, only kept for the ring buffer
+	dumped     bool
+}
+
+func newLogRecord(entry *logrus.Entry) *LogRecord {
+	record := &LogRecord{
+		Time:    entry.Time,
+		Level:   entry.Level.String(),
+		Message: entry.Message,
+		level:   entry.Level,
+		logger:  entry.Logger,
+	}
+	if entry.Logger != nil {
+		record.suppressed = entry.Level > effectiveLevel(entry.Logger)
+	}
+	if len(entry.Data) != 0 {
+		record.Fields = make(map[string]interface{}, len(entry.Data))
+	}
+	for key, value := range entry.Data {
+		if key == "module" {
+			record.Module = fmt.Sprint(value)
+			continue
+		}
+		switch v := value.(type) {
+		case string, bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+			record.Fields[key] = v
+		case error:
+			record.Fields[key] = v.Error()
+		default:
+			// keep the record safe to encode and independent of later changes to value
+			record.Fields[key] = fmt.Sprint(v)
+		}
+	}
+	return record
+}
+
+// dispatchHook is attached to every configured logger and fans entries out to the registered consumers
+type dispatchHook struct{}
+
+func (dispatchHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (dispatchHook) Fire(entry *logrus.Entry) error {
+	recordConsumersMu.RLock()
+	defer recordConsumersMu.RUnlock()
+	if len(recordConsumers) == 0 {
+		return nil
+	}
+
+	record := newLogRecord(entry)
+	for _, consumer := range recordConsumers {
+		consumer(record)
+	}
+	return nil
+}
+
+var recordConsumers = make(map[uint64]func(*LogRecord))
+var recordConsumersMu sync.RWMutex
+var nextRecordConsumer uint64
+
+// addRecordConsumer registers a function called for every log entry, it must not block. Call the returned function to remove it
+func addRecordConsumer(consumer func(*LogRecord)) func() {
+	recordConsumersMu.Lock()
+	defer recordConsumersMu.Unlock()
+	nextRecordConsumer++
+	id := nextRecordConsumer
+	recordConsumers[id] = consumer
+	return func() {
+		recordConsumersMu.Lock()
+		defer recordConsumersMu.Unlock()
+		delete(recordConsumers, id)
+	}
+}
+
+func attachDispatchHook(logger *logrus.Logger) {
+	for _, hook := range logger.Hooks[logrus.PanicLevel] {
+		if _, ok := hook.(dispatchHook); ok {
+			return
+		}
+	}
+	logger.AddHook(dispatchHook{})
+}
@@ -3,11 +3,10 @@ package testutils
 import (
 	"bytes"
 	"encoding/json"
-	"strconv"
-	"strings"
 	"testing"
 
 	. "github.com/s00500/env_logger"
+	"github.com/s00500/env_logger/logfmt"
 	"github.com/sirupsen/logrus"
 
 	"github.com/stretchr/testify/require"
@@ -37,9 +36,7 @@ func LogAndAssertText(t *testing.T, log func(*Entry), assertions func(fields map
 
 	loggerMain := logrus.New()
 	loggerMain.Out = &buffer
-	loggerMain.Formatter = &logrus.TextFormatter{
-		DisableColors: true,
-	}
+	loggerMain.Formatter = &logfmt.Formatter{}
 
 	ConfigureAllLoggers(loggerMain, "info")
 
@@ -47,20 +44,7 @@ func LogAndAssertText(t *testing.T, log func(*Entry), assertions func(fields map
 
 	log(logger)
 
-	fields := make(map[string]string)
-	for _, kv := range strings.Split(strings.TrimRight(buffer.String(), "\n"), " ") {
-		if !strings.Contains(kv, "=") {
-			continue
-		}
-		kvArr := strings.Split(kv, "=")
-		key := strings.TrimSpace(kvArr[0])
-		val := kvArr[1]
-		if kvArr[1][0] == '"' {
-			var err error
-			val, err = strconv.Unquote(val)
-			require.NoError(t, err)
-		}
-		fields[key] = val
-	}
+	fields, err := logfmt.Parse(buffer.String())
+	require.NoError(t, err)
 	assertions(fields)
 }
@@ -0,0 +1,183 @@
+// Package jsonformatter writes logrus entries as JSON following the OpenTelemetry log data model or the Elastic Common Schema.
+package jsonformatter
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Schema selects the field layout of the JSON output
+type Schema int
+
+const (
+	// SchemaOTel follows the OpenTelemetry log data model
+	SchemaOTel Schema = iota
+	// SchemaECS follows the Elastic Common Schema
+	SchemaECS
+)
+
+// ECSVersion is written as ecs.version in ECS output
+const ECSVersion = "8.11.0"
+
+// Formatter implements logrus.Formatter interface.
+type Formatter struct {
+	Schema Schema
+}
+
+// OpenTelemetry severity numbers of the first value of each range
+var severityNumbers = map[logrus.Level]int{
+	logrus.TraceLevel: 1,
+	logrus.DebugLevel: 5,
+	logrus.InfoLevel:  9,
+	logrus.WarnLevel:  13,
+	logrus.ErrorLevel: 17,
+	logrus.FatalLevel: 21,
+	logrus.PanicLevel: 24,
+}
+
+func severityText(level logrus.Level) string {
+	if level == logrus.WarnLevel {
+		return "WARN"
+	}
+	return strings.ToUpper(level.String())
+}
+
+// entryParts splits the entry data into the fields that have a place in the schemas and the remaining ones
+type entryParts struct {
+	module string
+	file   string
+	line   int
+	err    error
+	fields map[string]interface{}
+}
+
+func splitEntry(entry *logrus.Entry) entryParts {
+	parts := entryParts{fields: make(map[string]interface{}, len(entry.Data))}
+	for key, value := range entry.Data {
+		switch key {
+		case "module":
+			parts.module = fmt.Sprint(value)
+		case "file":
+			parts.file, parts.line = splitFileLine(fmt.Sprint(value))
+		case logrus.ErrorKey:
+			if err, ok := value.(error); ok {
+				parts.err = err
+				continue
+			}
+			parts.fields[key] = value
+		default:
+			if err, ok := value.(error); ok {
+				// errors have no exported fields, they would encode as {}
+				value = err.Error()
+			}
+			parts.fields[key] = value
+		}
+	}
+	return parts
+}
+
+// splitFileLine takes the file field of env_logger, formatted as 'path:line'
+func splitFileLine(file string) (string, int) {
+	file = strings.Trim(file, "'")
+	if i := strings.LastIndex(file, ":"); i != -1 {
+		if line, err := strconv.Atoi(file[i+1:]); err == nil {
+			return file[:i], line
+		}
+	}
+	return file, 0
+}
+
+// errorChain renders every error of the unwrap chain on its own line as type: message
+func errorChain(err error) string {
+	lines := make([]string, 0, 1)
+	for ; err != nil; err = errors.Unwrap(err) {
+		lines = append(lines, fmt.Sprintf("%T: %s", err, err.Error()))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Format building log message.
+func (f *Formatter) Format(entry *logrus.Entry) ([]byte, error) {
+	var data map[string]interface{}
+	if f.Schema == SchemaECS {
+		data = formatECS(entry)
+	} else {
+		data = formatOTel(entry)
+	}
+
+	buf := &bytes.Buffer{}
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(data); err != nil {
+		return nil, fmt.Errorf("failed to marshal fields to JSON, %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func formatOTel(entry *logrus.Entry) map[string]interface{} {
+	parts := splitEntry(entry)
+	attributes := parts.fields
+	if parts.file != "" {
+		attributes["code.filepath"] = parts.file
+		attributes["code.lineno"] = parts.line
+	}
+	if parts.err != nil {
+		attributes["exception.type"] = fmt.Sprintf("%T", parts.err)
+		attributes["exception.message"] = parts.err.Error()
+		attributes["exception.stacktrace"] = errorChain(parts.err)
+	}
+
+	data := map[string]interface{}{
+		// OTLP JSON encodes 64 bit integers as strings, nanoseconds do not fit into a float64
+		"time_unix_nano":  strconv.FormatInt(entry.Time.UnixNano(), 10),
+		"severity_text":   severityText(entry.Level),
+		"severity_number": severityNumbers[entry.Level],
+		"body":            entry.Message,
+	}
+	if len(attributes) != 0 {
+		data["attributes"] = attributes
+	}
+	if parts.module != "" {
+		data["instrumentation_scope"] = map[string]interface{}{"name": parts.module}
+	}
+	return data
+}
+
+func formatECS(entry *logrus.Entry) map[string]interface{} {
+	parts := splitEntry(entry)
+	data := make(map[string]interface{}, len(parts.fields)+8)
+	for key, value := range parts.fields {
+		data[key] = value
+	}
+	for _, key := range []string{"@timestamp", "message", "log", "error", "ecs"} {
+		if value, ok := data[key]; ok {
+			delete(data, key)
+			data["fields."+key] = value
+		}
+	}
+
+	data["@timestamp"] = entry.Time.UTC().Format(time.RFC3339Nano)
+	data["log.level"] = entry.Level.String()
+	data["message"] = entry.Message
+	data["ecs.version"] = ECSVersion
+	if parts.module != "" {
+		data["log.logger"] = parts.module
+	}
+	if parts.file != "" {
+		data["log.origin.file.name"] = parts.file
+		data["log.origin.file.line"] = parts.line
+	}
+	if parts.err != nil {
+		data["error.type"] = fmt.Sprintf("%T", parts.err)
+		data["error.message"] = parts.err.Error()
+		data["error.stack_trace"] = errorChain(parts.err)
+	}
+	return data
+}
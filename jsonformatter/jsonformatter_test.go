@@ -0,0 +1,81 @@
+package jsonformatter_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/s00500/env_logger/jsonformatter"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newEntry() *logrus.Entry {
+	return &logrus.Entry{
+		Time:    time.Date(2022, 1, 2, 15, 4, 5, 6, time.UTC),
+		Level:   logrus.WarnLevel,
+		Message: "read failed",
+		Data: logrus.Fields{
+			"module": "storage",
+			"file":   "'storage/disk.go:42'",
+			"error":  fmt.Errorf("reading header: %w", io.ErrUnexpectedEOF),
+			"bytes":  12,
+		},
+	}
+}
+
+func format(t *testing.T, schema jsonformatter.Schema, entry *logrus.Entry) map[string]interface{} {
+	out, err := (&jsonformatter.Formatter{Schema: schema}).Format(entry)
+	require.NoError(t, err)
+	var fields map[string]interface{}
+	require.NoError(t, json.Unmarshal(out, &fields), string(out))
+	return fields
+}
+
+func TestOTel(t *testing.T) {
+	fields := format(t, jsonformatter.SchemaOTel, newEntry())
+
+	assert.Equal(t, "1641135845000000006", fields["time_unix_nano"])
+	assert.Equal(t, "WARN", fields["severity_text"])
+	assert.Equal(t, float64(13), fields["severity_number"])
+	assert.Equal(t, "read failed", fields["body"])
+	assert.Equal(t, map[string]interface{}{"name": "storage"}, fields["instrumentation_scope"])
+
+	attributes := fields["attributes"].(map[string]interface{})
+	assert.Equal(t, "storage/disk.go", attributes["code.filepath"])
+	assert.Equal(t, float64(42), attributes["code.lineno"])
+	assert.Equal(t, float64(12), attributes["bytes"])
+	assert.Equal(t, "*fmt.wrapError", attributes["exception.type"])
+	assert.Equal(t, "reading header: unexpected EOF", attributes["exception.message"])
+	assert.Equal(t, "*fmt.wrapError: reading header: unexpected EOF\n*errors.errorString: unexpected EOF", attributes["exception.stacktrace"])
+	assert.NotContains(t, attributes, "module")
+}
+
+func TestECS(t *testing.T) {
+	entry := newEntry()
+	entry.Data["message"] = "clash"
+	fields := format(t, jsonformatter.SchemaECS, entry)
+
+	assert.Equal(t, "2022-01-02T15:04:05.000000006Z", fields["@timestamp"])
+	assert.Equal(t, "warning", fields["log.level"])
+	assert.Equal(t, "read failed", fields["message"])
+	assert.Equal(t, "clash", fields["fields.message"])
+	assert.Equal(t, "storage", fields["log.logger"])
+	assert.Equal(t, "storage/disk.go", fields["log.origin.file.name"])
+	assert.Equal(t, float64(42), fields["log.origin.file.line"])
+	assert.Equal(t, "*fmt.wrapError", fields["error.type"])
+	assert.Equal(t, "reading header: unexpected EOF", fields["error.message"])
+	assert.Equal(t, jsonformatter.ECSVersion, fields["ecs.version"])
+	assert.Equal(t, float64(12), fields["bytes"])
+}
+
+func TestMinimalEntry(t *testing.T) {
+	entry := &logrus.Entry{Level: logrus.InfoLevel, Message: "hi", Data: logrus.Fields{}}
+	fields := format(t, jsonformatter.SchemaOTel, entry)
+	assert.NotContains(t, fields, "attributes")
+	assert.NotContains(t, fields, "instrumentation_scope")
+	assert.Equal(t, float64(9), fields["severity_number"])
+}
@@ -0,0 +1,37 @@
+package env_logger
+
+import (
+	"context"
+	"fmt"
+	"runtime/pprof"
+	"sort"
+)
+
+// Do runs f with the module of the caller set as pprof label, cpu profiles can then be filtered by module
+func Do(ctx context.Context, f func(ctx context.Context)) {
+	module, _, _ := getCaller(3)
+	pprof.Do(ctx, pprof.Labels("module", module), f)
+}
+
+// Do runs f with the fields of the entry (including the module) set as pprof labels, cpu profiles can then be filtered by them
+func (e *Entry) Do(ctx context.Context, f func(ctx context.Context)) {
+	pprof.Do(ctx, pprof.Labels(e.labels()...), f)
+}
+
+// labels turns the fields into label pairs, call site fields are skipped as they would only split up the profile
+func (e *Entry) labels() []string {
+	keys := make([]string, 0, len(e.Data))
+	for key := range e.Data {
+		if key == "file" || key == "routines" {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	labels := make([]string, 0, 2*len(keys))
+	for _, key := range keys {
+		labels = append(labels, key, fmt.Sprint(e.Data[key]))
+	}
+	return labels
+}
@@ -0,0 +1,104 @@
+package env_logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	logrus "github.com/sirupsen/logrus"
+)
+
+type moduleLevelJSON struct {
+	Level      string `json:"level"`
+	Configured bool   `json:"configured"`
+}
+
+type logLevelsJSON struct {
+	Config  string                     `json:"config"`
+	Global  string                     `json:"global"`
+	Modules map[string]moduleLevelJSON `json:"modules"`
+}
+
+func currentLogLevels() logLevelsJSON {
+	levels := logLevelsJSON{
+		Config:  DebugConfig(),
+		Global:  levelName(effectiveLevel(lookupLogger("global_log"))),
+		Modules: make(map[string]moduleLevelJSON),
+	}
+	for module, level := range ModuleLevels() {
+		levels.Modules[module] = moduleLevelJSON{Level: levelName(level), Configured: IsModuleConfigured(module)}
+	}
+	return levels
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+func writeJSONError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// handleLogLevels serves GET /loglevels, PUT /loglevels/{module} and DELETE /loglevels/{module}
+func handleLogLevels(w http.ResponseWriter, r *http.Request) {
+	module := strings.Trim(strings.TrimPrefix(r.URL.Path, "/loglevels"), "/")
+
+	switch {
+	case r.Method == http.MethodGet && module == "":
+		writeJSON(w, http.StatusOK, currentLogLevels())
+	case r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, moduleLevelJSON{Level: levelName(effectiveLevel(lookupLogger(module))), Configured: IsModuleConfigured(module)})
+	case r.Method == http.MethodPut && module != "":
+		var body struct {
+			Level string `json:"level"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeJSONError(w, http.StatusBadRequest, fmt.Errorf("invalid body: %w", err))
+			return
+		}
+		level, err := logrus.ParseLevel(body.Level)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, err)
+			return
+		}
+		if err := SetModuleLevel(module, level); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, currentLogLevels())
+	case r.Method == http.MethodDelete && module != "":
+		if err := ResetModuleLevel(module); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, currentLogLevels())
+	default:
+		w.Header().Set("Allow", "GET, PUT, DELETE")
+		writeJSONError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed on %s", r.Method, r.URL.Path))
+	}
+}
+
+// handleFlags serves PUT /flags/{flag} and DELETE /flags/{flag} to switch flags like ln without touching the rest of the config
+func handleFlags(w http.ResponseWriter, r *http.Request) {
+	flag := strings.Trim(strings.TrimPrefix(r.URL.Path, "/flags"), "/")
+
+	var err error
+	switch r.Method {
+	case http.MethodPut:
+		err = SetConfigFlag(flag, true)
+	case http.MethodDelete:
+		err = SetConfigFlag(flag, false)
+	default:
+		w.Header().Set("Allow", "PUT, DELETE")
+		writeJSONError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed on %s", r.Method, r.URL.Path))
+		return
+	}
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, currentLogLevels())
+}
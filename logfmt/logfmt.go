@@ -0,0 +1,132 @@
+// Package logfmt formats logrus entries as logfmt lines and parses them back.
+//
+// Lines start with time, level and msg, followed by the fields sorted by key.
+// Values are quoted if they are empty or contain spaces, '=', '"' or control characters,
+// inside quotes backslash, quote, newline, carriage return and tab are escaped like in JSON.
+package logfmt
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Formatter implements logrus.Formatter interface.
+type Formatter struct {
+	// TimestampFormat defaults to time.RFC3339Nano
+	TimestampFormat string
+	// DisableTimestamp omits the time key
+	DisableTimestamp bool
+}
+
+// reserved keys are written first, fields with the same name get a fields. prefix
+var reserved = map[string]bool{"time": true, "level": true, "msg": true}
+
+// Format building log message.
+func (f *Formatter) Format(entry *logrus.Entry) ([]byte, error) {
+	buf := &bytes.Buffer{}
+
+	if !f.DisableTimestamp {
+		format := f.TimestampFormat
+		if format == "" {
+			format = time.RFC3339Nano
+		}
+		appendPair(buf, "time", entry.Time.Format(format))
+	}
+	appendPair(buf, "level", entry.Level.String())
+	appendPair(buf, "msg", entry.Message)
+
+	keys := make([]string, 0, len(entry.Data))
+	for key := range entry.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		name := key
+		if reserved[key] {
+			name = "fields." + key
+		}
+		appendPair(buf, name, valueString(entry.Data[key]))
+	}
+
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+func valueString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case error:
+		return v.Error()
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func appendPair(buf *bytes.Buffer, key, value string) {
+	if buf.Len() > 0 {
+		buf.WriteByte(' ')
+	}
+	buf.WriteString(sanitizeKey(key))
+	buf.WriteByte('=')
+	if needsQuoting(value) {
+		writeQuoted(buf, value)
+	} else {
+		buf.WriteString(value)
+	}
+}
+
+// sanitizeKey replaces everything that would end a key with an underscore
+func sanitizeKey(key string) string {
+	if key == "" {
+		return "_"
+	}
+	return strings.Map(func(r rune) rune {
+		if r <= ' ' || r == '=' || r == '"' || r == utf8.RuneError {
+			return '_'
+		}
+		return r
+	}, key)
+}
+
+func needsQuoting(value string) bool {
+	if value == "" {
+		return true
+	}
+	for _, r := range value {
+		if r <= ' ' || r == '=' || r == '"' || r == '\\' || r == utf8.RuneError {
+			return true
+		}
+	}
+	return false
+}
+
+func writeQuoted(buf *bytes.Buffer, value string) {
+	buf.WriteByte('"')
+	for _, r := range value {
+		switch r {
+		case '\\', '"':
+			buf.WriteByte('\\')
+			buf.WriteRune(r)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		default:
+			if r < ' ' || r == 0x7f {
+				fmt.Fprintf(buf, `\u%04x`, r)
+			} else {
+				buf.WriteRune(r)
+			}
+		}
+	}
+	buf.WriteByte('"')
+}
@@ -0,0 +1,49 @@
+package logfmt_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/s00500/env_logger/logfmt"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormat(t *testing.T) {
+	entry := &logrus.Entry{
+		Time:    time.Date(2022, 1, 2, 15, 4, 5, 6, time.UTC),
+		Level:   logrus.InfoLevel,
+		Message: "hello world",
+		Data:    logrus.Fields{"b": 1, "a": "x=y", "msg": "clash"},
+	}
+	out, err := (&logfmt.Formatter{}).Format(entry)
+	require.NoError(t, err)
+	assert.Equal(t, `time=2022-01-02T15:04:05.000000006Z level=info msg="hello world" a="x=y" b=1 fields.msg=clash`+"\n", string(out))
+}
+
+func TestRoundTrip(t *testing.T) {
+	values := []string{"", "plain", "with space", `with "quotes"`, "a=b", "multi\nline\ttab\r", `back\slash`, "ctrl\x01char", "ünïcödé"}
+	for _, value := range values {
+		entry := &logrus.Entry{Level: logrus.WarnLevel, Message: value, Data: logrus.Fields{"value": value}}
+		out, err := (&logfmt.Formatter{DisableTimestamp: true}).Format(entry)
+		require.NoError(t, err)
+
+		fields, err := logfmt.Parse(string(out))
+		require.NoError(t, err, string(out))
+		assert.Equal(t, value, fields["msg"])
+		assert.Equal(t, value, fields["value"])
+		assert.Equal(t, "warning", fields["level"])
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	fields, err := logfmt.Parse("flag a=1")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"flag": "", "a": "1"}, fields)
+
+	for _, line := range []string{`a="open`, `a=b"c`, `a="\x"`} {
+		_, err := logfmt.Parse(line)
+		assert.Error(t, err, line)
+	}
+}
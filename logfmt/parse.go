@@ -0,0 +1,96 @@
+package logfmt
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Parse reads a single logfmt line into its key value pairs, keys without value map to an empty string
+func Parse(line string) (map[string]string, error) {
+	fields := make(map[string]string)
+	line = strings.TrimRight(line, "\r\n")
+
+	i := 0
+	for i < len(line) {
+		if line[i] == ' ' {
+			i++
+			continue
+		}
+
+		start := i
+		for i < len(line) && line[i] != '=' && line[i] != ' ' {
+			if line[i] == '"' {
+				return nil, fmt.Errorf("unexpected quote in key at position %d", i)
+			}
+			i++
+		}
+		key := line[start:i]
+		if i >= len(line) || line[i] == ' ' {
+			fields[key] = ""
+			continue
+		}
+		i++ // skip =
+
+		if i < len(line) && line[i] == '"' {
+			value, n, err := unquote(line[i:])
+			if err != nil {
+				return nil, fmt.Errorf("value of %s: %w", key, err)
+			}
+			fields[key] = value
+			i += n
+			continue
+		}
+
+		start = i
+		for i < len(line) && line[i] != ' ' {
+			if line[i] == '"' || line[i] == '=' {
+				return nil, fmt.Errorf("unexpected %c in value of %s at position %d", line[i], key, i)
+			}
+			i++
+		}
+		fields[key] = line[start:i]
+	}
+	return fields, nil
+}
+
+// unquote decodes the quoted value at the start of s and returns it with the number of bytes consumed
+func unquote(s string) (string, int, error) {
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"':
+			return b.String(), i + 1, nil
+		case '\\':
+			if i+1 >= len(s) {
+				return "", 0, fmt.Errorf("unterminated escape")
+			}
+			i++
+			switch s[i] {
+			case '\\', '"':
+				b.WriteByte(s[i])
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case 'u':
+				if i+4 >= len(s) {
+					return "", 0, fmt.Errorf("short unicode escape")
+				}
+				r, err := strconv.ParseUint(s[i+1:i+5], 16, 32)
+				if err != nil {
+					return "", 0, fmt.Errorf("invalid unicode escape: %w", err)
+				}
+				b.WriteRune(rune(r))
+				i += 4
+			default:
+				return "", 0, fmt.Errorf("unknown escape \\%c", s[i])
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, fmt.Errorf("missing closing quote")
+}
@@ -0,0 +1,70 @@
+package env_logger
+
+import (
+	"sync"
+	"time"
+
+	"github.com/s00500/env_logger/netwriter"
+	logrus "github.com/sirupsen/logrus"
+)
+
+var netOutput *netwriter.Writer
+var netOutputFormatter logrus.Formatter
+var netOutputTarget string
+var netOutputMu sync.Mutex
+
+// exitFlushTimeout bounds how long Fatal waits for the queued entries to reach the log server before the process exits
+const exitFlushTimeout = 3 * time.Second
+
+var registerExitFlush sync.Once
+
+// configureNetOutput returns the writer and formatter for the out= token of the log config.
+// A writer for the same target is kept, one for a different target is closed after sending what it has queued
+func configureNetOutput(target string) (*netwriter.Writer, logrus.Formatter, error) {
+	netOutputMu.Lock()
+	defer netOutputMu.Unlock()
+
+	if netOutput != nil {
+		if netOutputTarget == target {
+			return netOutput, netOutputFormatter, nil
+		}
+		// close in the background, the old loggers might still be writing to it
+		go netOutput.Close()
+		netOutput, netOutputFormatter, netOutputTarget = nil, nil, ""
+	}
+	if target == "" {
+		return nil, nil, nil
+	}
+
+	writer, formatter, err := netwriter.Open(target, netwriter.Options{})
+	if err != nil {
+		return nil, nil, err
+	}
+	netOutput, netOutputFormatter, netOutputTarget = writer, formatter, target
+	registerExitFlush.Do(func() {
+		logrus.RegisterExitHandler(flushNetOutput)
+	})
+	return writer, formatter, nil
+}
+
+// flushNetOutput runs before Fatal exits the process, so the fatal entry itself still gets shipped
+func flushNetOutput() {
+	netOutputMu.Lock()
+	// a closed writer must not be reused by a later config, in case the exit func does not exit
+	writer := netOutput
+	netOutput, netOutputFormatter, netOutputTarget = nil, nil, ""
+	netOutputMu.Unlock()
+	if writer == nil {
+		return
+	}
+
+	done := make(chan struct{})
+	go func() {
+		writer.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(exitFlushTimeout):
+	}
+}
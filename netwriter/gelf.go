@@ -0,0 +1,84 @@
+package netwriter
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// gelfInvalidChars matches what GELF does not accept in the names of additional fields
+var gelfInvalidChars = regexp.MustCompile(`[^\w.\-]`)
+
+// syslogSeverity maps the logrus levels to syslog severities, GELF uses the same numbers
+var syslogSeverity = map[logrus.Level]int{
+	logrus.PanicLevel: 2,
+	logrus.FatalLevel: 2,
+	logrus.ErrorLevel: 3,
+	logrus.WarnLevel:  4,
+	logrus.InfoLevel:  6,
+	logrus.DebugLevel: 7,
+	logrus.TraceLevel: 7,
+}
+
+// GELFFormatter implements logrus.Formatter interface, writing GELF 1.1 messages without delimiter.
+type GELFFormatter struct {
+	// Host is sent as the source of the messages
+	Host string
+}
+
+// Format building log message.
+func (f *GELFFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	message := strings.TrimRight(entry.Message, "\n")
+	short := message
+	if i := strings.IndexByte(message, '\n'); i != -1 {
+		short = message[:i]
+	}
+
+	data := make(map[string]interface{}, len(entry.Data)+6)
+	for key, value := range entry.Data {
+		key = gelfInvalidChars.ReplaceAllString(key, "_")
+		if key == "id" || key == "" {
+			// _id is reserved by GELF
+			key = "field_" + key
+		}
+		data["_"+key] = gelfValue(value)
+	}
+	data["version"] = "1.1"
+	data["host"] = f.Host
+	data["short_message"] = short
+	if short != message {
+		data["full_message"] = message
+	}
+	data["timestamp"] = float64(entry.Time.UnixNano()/int64(1000)) / 1e6
+	data["level"] = syslogSeverity[entry.Level]
+
+	out, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal fields to JSON, %w", err)
+	}
+	return out, nil
+}
+
+// gelfValue keeps numbers and converts everything else to a string, GELF has no other field types
+func gelfValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return v
+	case string:
+		return v
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	case bool:
+		return fmt.Sprint(v)
+	default:
+		if data, err := json.Marshal(v); err == nil {
+			return string(data)
+		}
+		return fmt.Sprint(v)
+	}
+}
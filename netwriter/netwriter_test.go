@@ -0,0 +1,230 @@
+package netwriter_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/s00500/env_logger/netwriter"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newEntry(message string) *logrus.Entry {
+	return &logrus.Entry{
+		Time:    time.Date(2022, 1, 2, 15, 4, 5, 6000, time.UTC),
+		Level:   logrus.WarnLevel,
+		Message: message,
+		Data:    logrus.Fields{"module": "storage", "path": `C:\data "x"`, "id": 7},
+	}
+}
+
+func TestSyslogFormat(t *testing.T) {
+	out, err := (&netwriter.SyslogFormatter{Facility: 16, Hostname: "box", AppName: "app"}).Format(newEntry("disk full"))
+	require.NoError(t, err)
+	expected := `<132>1 2022-01-02T15:04:05.000006Z box app ` + strconv.Itoa(os.Getpid()) + ` storage [fields@32473 id="7" path="C:\\data \"x\""] disk full`
+	assert.Equal(t, expected, string(out))
+}
+
+func TestGELFFormat(t *testing.T) {
+	out, err := (&netwriter.GELFFormatter{Host: "box"}).Format(newEntry("disk full\nsecond line"))
+	require.NoError(t, err)
+
+	var fields map[string]interface{}
+	require.NoError(t, json.Unmarshal(out, &fields))
+	assert.Equal(t, "1.1", fields["version"])
+	assert.Equal(t, "box", fields["host"])
+	assert.Equal(t, "disk full", fields["short_message"])
+	assert.Equal(t, "disk full\nsecond line", fields["full_message"])
+	assert.Equal(t, float64(4), fields["level"])
+	assert.Equal(t, 1641135845.000006, fields["timestamp"])
+	assert.Equal(t, "storage", fields["_module"])
+	assert.Equal(t, float64(7), fields["_field_id"])
+}
+
+func TestGELFChunkedUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	w := netwriter.New("udp", conn.LocalAddr().String(), netwriter.FramingGELFChunked, netwriter.Options{ChunkSize: 100})
+	defer w.Close()
+	message := []byte(strings.Repeat("0123456789", 25))
+	_, err = w.Write(message)
+	require.NoError(t, err)
+
+	// 250 bytes with 88 bytes of data per chunk
+	chunks := make(map[byte][]byte)
+	buf := make([]byte, 200)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for len(chunks) < 3 {
+		n, _, err := conn.ReadFrom(buf)
+		require.NoError(t, err)
+		require.Equal(t, []byte{0x1e, 0x0f}, buf[:2])
+		assert.Equal(t, byte(3), buf[11])
+		chunks[buf[10]] = append([]byte(nil), buf[12:n]...)
+	}
+	assert.Equal(t, message, bytes.Join([][]byte{chunks[0], chunks[1], chunks[2]}, nil))
+}
+
+func TestSyslogTCPReconnects(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	w := netwriter.New("tcp", listener.Addr().String(), netwriter.FramingOctetCounting, netwriter.Options{MinBackoff: 10 * time.Millisecond})
+	defer w.Close()
+
+	w.Write([]byte("first\n"))
+	conn, err := listener.Accept()
+	require.NoError(t, err)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	line, err := bufio.NewReader(conn).ReadString('t')
+	require.NoError(t, err)
+	assert.Equal(t, "5 first", line)
+
+	// the server drops the connection, the writer has to dial again
+	conn.Close()
+	deadline := time.Now().Add(5 * time.Second)
+	var received string
+	for received == "" && time.Now().Before(deadline) {
+		w.Write([]byte("again"))
+		listener.(*net.TCPListener).SetDeadline(time.Now().Add(100 * time.Millisecond))
+		conn, err := listener.Accept()
+		if err != nil {
+			continue
+		}
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		buf := make([]byte, 7)
+		if _, err := conn.Read(buf); err == nil {
+			received = string(buf)
+		}
+		conn.Close()
+	}
+	assert.Equal(t, "5 again", received)
+}
+
+func TestGELFTCPNullTerminated(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	w, formatter, err := netwriter.Open("gelf+tcp://"+listener.Addr().String(), netwriter.Options{})
+	require.NoError(t, err)
+	defer w.Close()
+	logger := logrus.New()
+	logger.Out, logger.Formatter = w, formatter
+	logger.Info("hello")
+
+	conn, err := listener.Accept()
+	require.NoError(t, err)
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	message, err := bufio.NewReader(conn).ReadBytes(0)
+	require.NoError(t, err)
+
+	var fields map[string]interface{}
+	require.NoError(t, json.Unmarshal(message[:len(message)-1], &fields))
+	assert.Equal(t, "hello", fields["short_message"])
+}
+
+func TestSyslogUnixSocket(t *testing.T) {
+	dir, err := os.MkdirTemp("", "netwriter")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "log.sock")
+	conn, err := net.ListenPacket("unixgram", path)
+	if err != nil {
+		t.Skip("unix datagram sockets are not supported:", err)
+	}
+	defer conn.Close()
+
+	w, formatter, err := netwriter.Open("syslog+unix://"+path+"?facility=local0", netwriter.Options{})
+	require.NoError(t, err)
+	defer w.Close()
+	logger := logrus.New()
+	logger.Out, logger.Formatter = w, formatter
+	logger.Error("boom")
+
+	buf := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(buf[:n]), "<131>1 "), string(buf[:n]))
+	assert.True(t, strings.HasSuffix(string(buf[:n]), " - - boom"), string(buf[:n]))
+}
+
+func TestBufferDropsWhenUnreachable(t *testing.T) {
+	// a listener that is closed right away gives an address nobody accepts on
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := listener.Addr().String()
+	listener.Close()
+
+	w := netwriter.New("tcp", addr, netwriter.FramingOctetCounting, netwriter.Options{BufferSize: 2, MinBackoff: time.Hour})
+	for i := 0; i < 10; i++ {
+		n, err := w.Write([]byte("lost"))
+		assert.NoError(t, err)
+		assert.Equal(t, 4, n)
+	}
+	assert.GreaterOrEqual(t, w.Dropped(), uint64(7))
+
+	w.Close()
+	assert.Equal(t, uint64(10), w.Dropped())
+	_, err = w.Write([]byte("closed"))
+	assert.ErrorIs(t, err, netwriter.ErrClosed)
+}
+
+func TestOpenRejectsUnknownTargets(t *testing.T) {
+	for _, target := range []string{"http://host", "gelf://", "syslog+unix://", "syslog://host:514?facility=nope"} {
+		_, _, err := netwriter.Open(target, netwriter.Options{})
+		assert.Error(t, err, target)
+	}
+}
+
+func TestEmptyWritesIgnored(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	w := netwriter.New("udp", conn.LocalAddr().String(), netwriter.FramingDatagram, netwriter.Options{})
+	defer w.Close()
+	for _, p := range [][]byte{nil, {}, []byte("\n")} {
+		n, err := w.Write(p)
+		assert.NoError(t, err)
+		assert.Equal(t, len(p), n)
+	}
+	w.Write([]byte("real"))
+
+	buf := make([]byte, 100)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	require.NoError(t, err)
+	assert.Equal(t, "real", string(buf[:n]))
+}
+
+func TestOversizedDatagramDropped(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	w := netwriter.New("udp", conn.LocalAddr().String(), netwriter.FramingDatagram, netwriter.Options{})
+	defer w.Close()
+	w.Write(bytes.Repeat([]byte("x"), 70000))
+	w.Write([]byte("small"))
+
+	buf := make([]byte, 100)
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	require.NoError(t, err)
+	assert.Equal(t, "small", string(buf[:n]))
+	assert.Equal(t, uint64(1), w.Dropped())
+}
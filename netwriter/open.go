@@ -0,0 +1,66 @@
+package netwriter
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Open starts a writer for a target url and returns the formatter matching its protocol. Supported targets are
+//
+//	gelf://host:12201 (udp), gelf+udp://host:12201, gelf+tcp://host:12201
+//	syslog://host:514 (udp), syslog+udp://host:514, syslog+tcp://host:601, syslog+unix:///dev/log
+//
+// Syslog targets accept the facility as query parameter, eg. syslog+udp://host:514?facility=local0
+func Open(target string, opts Options) (*Writer, logrus.Formatter, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, nil, err
+	}
+	hostname, _ := os.Hostname()
+
+	switch u.Scheme {
+	case "gelf", "gelf+udp", "gelf+tcp":
+		if u.Host == "" {
+			return nil, nil, fmt.Errorf("missing host in %s", target)
+		}
+		formatter := &GELFFormatter{Host: hostname}
+		if u.Scheme == "gelf+tcp" {
+			return New("tcp", u.Host, FramingNullTerminated, opts), formatter, nil
+		}
+		return New("udp", u.Host, FramingGELFChunked, opts), formatter, nil
+
+	case "syslog", "syslog+udp", "syslog+tcp", "syslog+unix":
+		formatter := &SyslogFormatter{Facility: FacilityUser, Hostname: hostname, AppName: filepath.Base(os.Args[0])}
+		if name := u.Query().Get("facility"); name != "" {
+			facility, ok := facilities[name]
+			if !ok {
+				return nil, nil, fmt.Errorf("unknown syslog facility '%s'", name)
+			}
+			formatter.Facility = facility
+		}
+		switch u.Scheme {
+		case "syslog+unix":
+			if u.Path == "" {
+				return nil, nil, fmt.Errorf("missing socket path in %s", target)
+			}
+			return New("unix", u.Path, FramingDatagram, opts), formatter, nil
+		case "syslog+tcp":
+			if u.Host == "" {
+				return nil, nil, fmt.Errorf("missing host in %s", target)
+			}
+			return New("tcp", u.Host, FramingOctetCounting, opts), formatter, nil
+		default:
+			if u.Host == "" {
+				return nil, nil, fmt.Errorf("missing host in %s", target)
+			}
+			return New("udp", u.Host, FramingDatagram, opts), formatter, nil
+		}
+
+	default:
+		return nil, nil, fmt.Errorf("unsupported output '%s'", target)
+	}
+}
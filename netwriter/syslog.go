@@ -0,0 +1,111 @@
+package netwriter
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// FacilityUser is the syslog facility used when none is set
+const FacilityUser = 1
+
+// facilities maps the syslog facility names to their codes
+var facilities = map[string]int{
+	"user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5, "lpr": 6, "news": 7,
+	"uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19, "local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// sdID is the structured data element the fields are sent in, 32473 is the enterprise number reserved for documentation
+const sdID = "fields@32473"
+
+// SyslogFormatter implements logrus.Formatter interface, writing RFC 5424 messages without delimiter.
+// The module is sent as MSGID and all other fields as structured data
+type SyslogFormatter struct {
+	// Facility is the syslog facility code, 0 (kern) is replaced by FacilityUser
+	Facility int
+	Hostname string
+	AppName  string
+}
+
+// Format building log message.
+func (f *SyslogFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	facility := f.Facility
+	if facility <= 0 {
+		facility = FacilityUser
+	}
+	pri := facility*8 + syslogSeverity[entry.Level]
+
+	msgID := "-"
+	if module, ok := entry.Data["module"]; ok {
+		msgID = headerField(fmt.Sprint(module), 32)
+	}
+
+	keys := make([]string, 0, len(entry.Data))
+	for key := range entry.Data {
+		if key != "module" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	structured := "-"
+	if len(keys) != 0 {
+		var b strings.Builder
+		b.WriteString("[" + sdID)
+		for _, key := range keys {
+			fmt.Fprintf(&b, ` %s="%s"`, paramName(key), paramValue(fmt.Sprint(entry.Data[key])))
+		}
+		b.WriteString("]")
+		structured = b.String()
+	}
+
+	line := fmt.Sprintf("<%d>1 %s %s %s %d %s %s",
+		pri,
+		entry.Time.Format("2006-01-02T15:04:05.000000Z07:00"),
+		headerField(f.Hostname, 255),
+		headerField(f.AppName, 48),
+		os.Getpid(),
+		msgID,
+		structured,
+	)
+	if message := strings.TrimRight(entry.Message, "\n"); message != "" {
+		line += " " + message
+	}
+	return []byte(line), nil
+}
+
+// headerField replaces everything but printable ASCII and cuts s to limit characters, an empty field is written as -
+func headerField(s string, limit int) string {
+	s = strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return '_'
+		}
+		return r
+	}, s)
+	if len(s) > limit {
+		s = s[:limit]
+	}
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// paramName removes the characters RFC 5424 forbids in parameter names
+func paramName(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r == '=' || r == ']' || r == '"' {
+			return '_'
+		}
+		return r
+	}, s)
+	return headerField(s, 32)
+}
+
+// paramValue escapes the characters RFC 5424 requires to be escaped in parameter values
+func paramValue(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(s)
+}
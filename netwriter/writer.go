@@ -0,0 +1,297 @@
+// Package netwriter ships log entries to GELF and syslog servers.
+// The Writer queues every entry and sends it from a background goroutine, reconnecting with a backoff, so logging never blocks on the network.
+package netwriter
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// Framing selects how messages are delimited on the connection
+type Framing int
+
+const (
+	// FramingDatagram sends every message as its own packet
+	FramingDatagram Framing = iota
+	// FramingGELFChunked sends every message as its own packet and splits messages larger than ChunkSize into GELF chunks
+	FramingGELFChunked
+	// FramingOctetCounting prefixes every message with its length as described in RFC 6587
+	FramingOctetCounting
+	// FramingNullTerminated ends every message with a null byte, as GELF over TCP expects
+	FramingNullTerminated
+)
+
+const (
+	defaultBufferSize = 1024
+	defaultMinBackoff = 100 * time.Millisecond
+	defaultMaxBackoff = 30 * time.Second
+	defaultChunkSize  = 1420
+
+	// dialTimeout and writeTimeout bound the time a single attempt blocks the sender
+	dialTimeout  = 5 * time.Second
+	writeTimeout = 5 * time.Second
+
+	// maxDatagramSize is the largest UDP payload, larger datagrams can never be sent
+	maxDatagramSize = 65507
+
+	gelfChunkHeader = 12
+	gelfMaxChunks   = 128
+)
+
+// ErrClosed is returned by Write after Close
+var ErrClosed = errors.New("netwriter: writer closed")
+
+// errTooLarge marks a message that can never be sent, it is dropped instead of retried
+var errTooLarge = errors.New("netwriter: message too large")
+
+// Options tunes a Writer, zero values use the defaults
+type Options struct {
+	// BufferSize is the number of messages queued while the server is unreachable, defaults to 1024. Messages beyond that are dropped
+	BufferSize int
+	// MinBackoff and MaxBackoff bound the wait between reconnects, defaults are 100ms and 30s
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// ChunkSize is the maximum packet size for FramingGELFChunked, defaults to 1420
+	ChunkSize int
+}
+
+// Writer sends every Write as one message to a server
+type Writer struct {
+	network string
+	addr    string
+	framing Framing
+	opts    Options
+
+	queue   chan []byte
+	done    chan struct{}
+	stopped chan struct{}
+	once    sync.Once
+	dropped uint64
+
+	// only used by the sender goroutine
+	conn        net.Conn
+	connFraming Framing
+}
+
+// New starts a writer sending to addr. For the unix network a datagram socket is tried first and a stream socket with octet counting after that
+func New(network, addr string, framing Framing, opts Options) *Writer {
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = defaultBufferSize
+	}
+	if opts.MinBackoff <= 0 {
+		opts.MinBackoff = defaultMinBackoff
+	}
+	if opts.MaxBackoff < opts.MinBackoff {
+		opts.MaxBackoff = defaultMaxBackoff
+		if opts.MaxBackoff < opts.MinBackoff {
+			opts.MaxBackoff = opts.MinBackoff
+		}
+	}
+	if opts.ChunkSize <= gelfChunkHeader {
+		opts.ChunkSize = defaultChunkSize
+	}
+
+	w := &Writer{
+		network: network,
+		addr:    addr,
+		framing: framing,
+		opts:    opts,
+		queue:   make(chan []byte, opts.BufferSize),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// Write queues p as one message, trailing newlines are removed and empty messages ignored. It never blocks, if the queue is full the message is dropped
+func (w *Writer) Write(p []byte) (int, error) {
+	select {
+	case <-w.done:
+		return 0, ErrClosed
+	default:
+	}
+
+	msg := bytes.TrimRight(p, "\n")
+	if len(msg) == 0 {
+		// logrus writes the empty result of formatters that suppress an entry
+		return len(p), nil
+	}
+	msg = append(make([]byte, 0, len(msg)), msg...)
+	select {
+	case w.queue <- msg:
+	default:
+		atomic.AddUint64(&w.dropped, 1)
+	}
+	return len(p), nil
+}
+
+// Dropped returns the number of messages lost because the queue was full or they were too large to send
+func (w *Writer) Dropped() uint64 {
+	return atomic.LoadUint64(&w.dropped)
+}
+
+// Close stops the writer after trying once to send the messages still queued
+func (w *Writer) Close() error {
+	w.once.Do(func() {
+		close(w.done)
+	})
+	<-w.stopped
+	return nil
+}
+
+func (w *Writer) run() {
+	defer close(w.stopped)
+	defer w.disconnect()
+
+	backoff := w.opts.MinBackoff
+	for {
+		var msg []byte
+		select {
+		case msg = <-w.queue:
+		case <-w.done:
+			w.drain()
+			return
+		}
+
+		for {
+			err := w.send(msg)
+			if err == nil {
+				backoff = w.opts.MinBackoff
+				break
+			}
+			if errors.Is(err, errTooLarge) {
+				atomic.AddUint64(&w.dropped, 1)
+				break
+			}
+			w.disconnect()
+			select {
+			case <-time.After(backoff):
+			case <-w.done:
+				atomic.AddUint64(&w.dropped, 1)
+				w.drain()
+				return
+			}
+			backoff *= 2
+			if backoff > w.opts.MaxBackoff {
+				backoff = w.opts.MaxBackoff
+			}
+		}
+	}
+}
+
+// drain sends what is left in the queue without retrying
+func (w *Writer) drain() {
+	for {
+		select {
+		case msg := <-w.queue:
+			if err := w.send(msg); err != nil {
+				atomic.AddUint64(&w.dropped, uint64(1+len(w.queue)))
+				return
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (w *Writer) connect() error {
+	if w.conn != nil {
+		return nil
+	}
+	if w.network == "unix" {
+		// syslog daemons usually listen on a datagram socket
+		if conn, err := net.DialTimeout("unixgram", w.addr, dialTimeout); err == nil {
+			w.conn, w.connFraming = conn, FramingDatagram
+			return nil
+		}
+		conn, err := net.DialTimeout("unix", w.addr, dialTimeout)
+		if err != nil {
+			return err
+		}
+		w.conn, w.connFraming = conn, FramingOctetCounting
+		return nil
+	}
+	conn, err := net.DialTimeout(w.network, w.addr, dialTimeout)
+	if err != nil {
+		return err
+	}
+	w.conn, w.connFraming = conn, w.framing
+	return nil
+}
+
+func (w *Writer) disconnect() {
+	if w.conn != nil {
+		w.conn.Close()
+		w.conn = nil
+	}
+}
+
+func (w *Writer) send(msg []byte) error {
+	if err := w.connect(); err != nil {
+		return err
+	}
+	w.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+
+	if w.connFraming == FramingDatagram && len(msg) > maxDatagramSize {
+		return errTooLarge
+	}
+
+	var err error
+	switch w.connFraming {
+	case FramingGELFChunked:
+		err = writeChunked(w.conn, msg, w.opts.ChunkSize)
+	case FramingOctetCounting:
+		_, err = w.conn.Write(append([]byte(fmt.Sprintf("%d ", len(msg))), msg...))
+	case FramingNullTerminated:
+		_, err = w.conn.Write(append(msg, 0))
+	default:
+		_, err = w.conn.Write(msg)
+	}
+	if errors.Is(err, syscall.EMSGSIZE) {
+		// the socket rejects the datagram size, retrying would block the queue forever
+		return errTooLarge
+	}
+	return err
+}
+
+// writeChunked splits msg into GELF chunks: magic bytes, message id, sequence number and sequence count followed by the data
+func writeChunked(conn net.Conn, msg []byte, size int) error {
+	if len(msg) <= size {
+		_, err := conn.Write(msg)
+		return err
+	}
+
+	dataSize := size - gelfChunkHeader
+	count := (len(msg) + dataSize - 1) / dataSize
+	if count > gelfMaxChunks {
+		return errTooLarge
+	}
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return err
+	}
+
+	chunk := make([]byte, 0, size)
+	for i := 0; i < count; i++ {
+		end := (i + 1) * dataSize
+		if end > len(msg) {
+			end = len(msg)
+		}
+		chunk = append(chunk[:0], 0x1e, 0x0f)
+		chunk = append(chunk, id...)
+		chunk = append(chunk, byte(i), byte(count))
+		chunk = append(chunk, msg[i*dataSize:end]...)
+		if _, err := conn.Write(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package env_logger
+
+import (
+	"fmt"
+	"time"
+
+	logrus "github.com/sirupsen/logrus"
+)
+
+// levelOverride is a temporary change of the log config, either of a single module or of the whole config
+type levelOverride struct {
+	id     uint64
+	module string
+	level  string
+	config string
+	timer  *time.Timer
+}
+
+func (o *levelOverride) apply(debugConfig string) string {
+	if o.module == "" {
+		return o.config
+	}
+	return withModuleLevel(debugConfig, o.module, o.level)
+}
+
+func (o *levelOverride) String() string {
+	if o.module == "" {
+		return fmt.Sprintf("config '%s'", o.config)
+	}
+	return fmt.Sprintf("module %s=%s", o.module, o.level)
+}
+
+// overrides is a stack on top of overrideBase, both are guarded by configMu
+var overrides []*levelOverride
+var overrideBase string
+var nextOverrideID uint64
+
+// baseConfigLocked returns the config without temporary overrides
+func baseConfigLocked() string {
+	if len(overrides) == 0 {
+		return DebugConfig()
+	}
+	return overrideBase
+}
+
+// setBaseConfigLocked changes the permanent config and reapplies active overrides on top
+func setBaseConfigLocked(debugConfig string) {
+	if len(overrides) == 0 {
+		applyDebugConfig(debugConfig)
+		return
+	}
+	overrideBase = debugConfig
+	applyDebugConfig(effectiveConfigLocked())
+}
+
+func effectiveConfigLocked() string {
+	debugConfig := overrideBase
+	for _, o := range overrides {
+		debugConfig = o.apply(debugConfig)
+	}
+	return debugConfig
+}
+
+func pushOverride(o *levelOverride, ttl time.Duration) {
+	configMu.Lock()
+	defer configMu.Unlock()
+
+	if len(overrides) == 0 {
+		overrideBase = DebugConfig()
+	}
+	nextOverrideID++
+	o.id = nextOverrideID
+	overrides = append(overrides, o)
+	applyDebugConfig(effectiveConfigLocked())
+
+	id := o.id
+	o.timer = time.AfterFunc(ttl, func() {
+		expireOverride(id)
+	})
+	Warnf("temporary log override of %s active for %s", o, ttl)
+}
+
+func expireOverride(id uint64) {
+	configMu.Lock()
+	defer configMu.Unlock()
+
+	for i, o := range overrides {
+		if o.id != id {
+			continue
+		}
+		overrides = append(overrides[:i], overrides[i+1:]...)
+		applyDebugConfig(effectiveConfigLocked())
+		Warnf("temporary log override of %s expired, log config is '%s' again", o, DebugConfig())
+		return
+	}
+}
+
+// SetModuleLevelFor changes the level of a single module until ttl has passed, then the previous level is restored
+func SetModuleLevelFor(module string, level logrus.Level, ttl time.Duration) error {
+	if err := validateModuleName(module); err != nil {
+		return err
+	}
+	if ttl <= 0 {
+		return fmt.Errorf("ttl has to be positive")
+	}
+	pushOverride(&levelOverride{module: module, level: levelName(level)}, ttl)
+	return nil
+}
+
+// SetGlobalDebugConfigFor replaces the whole log config until ttl has passed, then the previous config is restored
+func SetGlobalDebugConfigFor(debugConfig string, ttl time.Duration) error {
+	if ttl <= 0 {
+		return fmt.Errorf("ttl has to be positive")
+	}
+	pushOverride(&levelOverride{config: debugConfig}, ttl)
+	return nil
+}
+
+// ResetLevelOverrides drops all temporary overrides immediately and restores the permanent config
+func ResetLevelOverrides() {
+	configMu.Lock()
+	defer configMu.Unlock()
+	if len(overrides) == 0 {
+		return
+	}
+	for _, o := range overrides {
+		o.timer.Stop()
+	}
+	overrides = nil
+	applyDebugConfig(overrideBase)
+	Warnf("temporary log overrides reset, log config is '%s' again", overrideBase)
+}
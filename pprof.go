@@ -4,35 +4,14 @@
 package env_logger
 
 import (
-	"fmt"
-	"io/ioutil"
 	"net/http"
-	_ "net/http/pprof"
-	"strings"
+	"net/http/pprof"
 )
 
-func init() {
-
-}
-
-func profileServer(port uint16) {
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		fmt.Fprintf(w, "")
-	})
-
-	http.HandleFunc("/logstring", func(w http.ResponseWriter, r *http.Request) {
-		// function to allow dynamicaly setting the logstring
-		body, err := ioutil.ReadAll(r.Body)
-		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			fmt.Fprintf(w, "Error: "+err.Error())
-			return
-		}
-		debugConfig := strings.TrimSpace(string(body))
-		SetGlobalDebugConfig(debugConfig)
-
-		fmt.Fprintf(w, "New log config: %s", debugConfig)
-	})
-	Warnf("profileserver startet on port %d", port)
-	Error(http.ListenAndServe(fmt.Sprintf(":%d", port), nil))
+func registerProfileHandlers(mux *http.ServeMux) {
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
 }
@@ -0,0 +1,200 @@
+package env_logger
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"sort"
+	"sync"
+	"time"
+)
+
+// defaultProfileKeep is the number of files per profile kind kept when profkeep is not set
+const defaultProfileKeep = 10
+
+// profileOutput describes where profiles, dumps and traces are written to
+type profileOutput struct {
+	dir  string
+	keep int
+}
+
+func currentProfileOutput() profileOutput {
+	loggersMu.RLock()
+	defer loggersMu.RUnlock()
+	return activeProfileOutput
+}
+
+func (o profileOutput) pattern(kind, ext string) string {
+	return filepath.Join(o.dir, fmt.Sprintf("%s-%s-*%s", filepath.Base(os.Args[0]), kind, ext))
+}
+
+// create opens a new timestamped file for kind in the profile directory
+func (o profileOutput) create(kind, ext string) (*os.File, error) {
+	if err := os.MkdirAll(o.dir, 0o755); err != nil {
+		return nil, err
+	}
+	name := fmt.Sprintf("%s-%s-%s%s", filepath.Base(os.Args[0]), kind, time.Now().Format("20060102T150405.000"), ext)
+	return os.Create(filepath.Join(o.dir, name))
+}
+
+// prune removes the oldest files of kind, so only keep of them stay on disk
+func (o profileOutput) prune(kind, ext string) {
+	if o.keep <= 0 {
+		return
+	}
+	files, err := filepath.Glob(o.pattern(kind, ext))
+	if err != nil || len(files) <= o.keep {
+		return
+	}
+	// the timestamp format sorts lexically
+	sort.Strings(files)
+	for _, file := range files[:len(files)-o.keep] {
+		os.Remove(file)
+	}
+}
+
+// write stores one profile of kind, written by write, and applies the retention
+func (o profileOutput) write(kind, ext string, write func(w io.Writer) error) (string, error) {
+	f, err := o.create(kind, ext)
+	if err != nil {
+		return "", err
+	}
+	err = write(f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	o.prune(kind, ext)
+	return f.Name(), nil
+}
+
+func writeNamedProfile(name string, debug int) func(w io.Writer) error {
+	return func(w io.Writer) error {
+		profile := pprof.Lookup(name)
+		if profile == nil {
+			return fmt.Errorf("unknown profile %s", name)
+		}
+		return profile.WriteTo(w, debug)
+	}
+}
+
+// collectorConfig is the part of the log config a profile collector depends on
+type collectorConfig struct {
+	out       profileOutput
+	interval  time.Duration
+	blockRate int
+}
+
+// profileCollector is a collector started by the log config, guarded by profileCollectorsMu
+type profileCollector struct {
+	config collectorConfig
+	cancel context.CancelFunc
+}
+
+var cpuCollector, heapCollector profileCollector
+var profileCollectorsMu sync.Mutex
+
+// restart runs collect for config unless it already runs with the same config, an interval of 0 stops the collector
+func (c *profileCollector) restart(config collectorConfig, collect func(ctx context.Context, config collectorConfig)) {
+	if c.cancel != nil && c.config == config {
+		return
+	}
+	if c.cancel != nil {
+		c.cancel()
+		c.cancel = nil
+	}
+	c.config = config
+	if config.interval <= 0 {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+	go collect(ctx, config)
+}
+
+// startConfiguredProfiles starts the collectors of cpuprof and heapprof. Every level change replaces the whole config,
+// so a collector only restarts when its own tokens, dir or profkeep change instead of cutting the running profile short
+func startConfiguredProfiles(out profileOutput, cpuDuration, heapInterval time.Duration, blockRate int) {
+	profileCollectorsMu.Lock()
+	defer profileCollectorsMu.Unlock()
+	cpuCollector.restart(collectorConfig{out: out, interval: cpuDuration}, func(ctx context.Context, config collectorConfig) {
+		collectCPUProfiles(ctx, config.out, config.interval)
+	})
+	heapCollector.restart(collectorConfig{out: out, interval: heapInterval, blockRate: blockRate}, func(ctx context.Context, config collectorConfig) {
+		collectProfiles(ctx, config.out, config.interval, config.blockRate)
+	})
+}
+
+// collectCPUProfiles records back to back cpu profiles of the given duration until ctx is done
+func collectCPUProfiles(ctx context.Context, out profileOutput, duration time.Duration) {
+	log := GetLoggerForPrefix("profiles")
+	for {
+		f, err := out.create("cpu", ".pprof")
+		if err != nil {
+			log.Errorf("could not create cpu profile: %v", err)
+			return
+		}
+		started := true
+		if err := pprof.StartCPUProfile(f); err != nil {
+			// another cpu profile is running, eg. from /debug/pprof/profile, try again next round and leave it alone
+			started = false
+			f.Close()
+			os.Remove(f.Name())
+			log.Warnf("could not start cpu profile: %v", err)
+		}
+
+		t := time.NewTimer(duration)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+		case <-t.C:
+		}
+
+		if started {
+			pprof.StopCPUProfile()
+			if err := f.Close(); err == nil {
+				out.prune("cpu", ".pprof")
+				log.WithField("file", f.Name()).Debug("wrote cpu profile")
+			}
+		}
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// collectProfiles writes heap, and if their rates are set mutex and block, profiles in the given interval
+func collectProfiles(ctx context.Context, out profileOutput, interval time.Duration, blockRate int) {
+	log := GetLoggerForPrefix("profiles")
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			kinds := []string{"heap"}
+			if runtime.SetMutexProfileFraction(-1) > 0 {
+				kinds = append(kinds, "mutex")
+			}
+			if blockRate > 0 {
+				kinds = append(kinds, "block")
+			}
+			for _, kind := range kinds {
+				file, err := out.write(kind, ".pprof", writeNamedProfile(kind, 0))
+				if err != nil {
+					log.Errorf("could not write %s profile: %v", kind, err)
+					continue
+				}
+				log.WithField("file", file).Debugf("wrote %s profile", kind)
+			}
+		}
+	}
+}
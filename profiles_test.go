@@ -0,0 +1,130 @@
+package env_logger
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime/pprof"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func tempProfileOutput(t *testing.T, keep int) profileOutput {
+	dir, err := os.MkdirTemp("", "profiles")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return profileOutput{dir: dir, keep: keep}
+}
+
+func profileFiles(t *testing.T, out profileOutput, kind string) []string {
+	files, err := filepath.Glob(out.pattern(kind, ".pprof"))
+	require.NoError(t, err)
+	return files
+}
+
+func TestProfilePrune(t *testing.T) {
+	out := tempProfileOutput(t, 2)
+	binary := filepath.Base(os.Args[0])
+	for _, name := range []string{"cpu-20220101T000000.003", "cpu-20220101T000000.001", "cpu-20220101T000000.002", "heap-20220101T000000.001"} {
+		require.NoError(t, os.WriteFile(filepath.Join(out.dir, binary+"-"+name+".pprof"), nil, 0o644))
+	}
+
+	out.prune("cpu", ".pprof")
+	assert.Equal(t, []string{
+		filepath.Join(out.dir, binary+"-cpu-20220101T000000.002.pprof"),
+		filepath.Join(out.dir, binary+"-cpu-20220101T000000.003.pprof"),
+	}, profileFiles(t, out, "cpu"))
+	assert.Len(t, profileFiles(t, out, "heap"), 1)
+
+	// keep 0 disables the retention
+	out.keep = 0
+	require.NoError(t, os.WriteFile(filepath.Join(out.dir, binary+"-cpu-20220101T000000.004.pprof"), nil, 0o644))
+	out.prune("cpu", ".pprof")
+	assert.Len(t, profileFiles(t, out, "cpu"), 3)
+}
+
+func TestProfileWrite(t *testing.T) {
+	out := tempProfileOutput(t, 1)
+	file, err := out.write("heap", ".pprof", writeNamedProfile("heap", 0))
+	require.NoError(t, err)
+	info, err := os.Stat(file)
+	require.NoError(t, err)
+	assert.NotZero(t, info.Size())
+
+	_, err = out.write("nope", ".pprof", writeNamedProfile("nope", 0))
+	assert.Error(t, err)
+	assert.Empty(t, profileFiles(t, out, "nope"))
+}
+
+func TestCollectCPUProfilesRotates(t *testing.T) {
+	configureQuietLoggers(t, "info")
+	out := tempProfileOutput(t, 2)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		collectCPUProfiles(ctx, out, 20*time.Millisecond)
+		close(done)
+	}()
+	// file names have millisecond precision, wait for a few rotations
+	time.Sleep(150 * time.Millisecond)
+	cancel()
+	<-done
+
+	assert.Len(t, profileFiles(t, out, "cpu"), 2)
+	// the profiler is free again
+	require.NoError(t, pprof.StartCPUProfile(io.Discard))
+	pprof.StopCPUProfile()
+}
+
+func TestCollectCPUProfilesLeavesForeignProfile(t *testing.T) {
+	configureQuietLoggers(t, "info")
+	out := tempProfileOutput(t, 2)
+	require.NoError(t, pprof.StartCPUProfile(io.Discard))
+	defer pprof.StopCPUProfile()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		collectCPUProfiles(ctx, out, 10*time.Millisecond)
+		close(done)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	<-done
+
+	assert.Empty(t, profileFiles(t, out, "cpu"))
+	// the profile started by the test is still running
+	assert.Error(t, pprof.StartCPUProfile(io.Discard))
+}
+
+func TestConfiguredProfilesSurviveLevelChanges(t *testing.T) {
+	out := tempProfileOutput(t, 2)
+	configureQuietLoggers(t, "info,cpuprof=1h,dir="+out.dir)
+	var running []string
+	require.Eventually(t, func() bool {
+		running = profileFiles(t, out, "cpu")
+		return len(running) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// a level change keeps the running cpu profile instead of writing a truncated one and starting the next
+	configureQuietLoggers(t, "debug,cpuprof=1h,dir="+out.dir)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, running, profileFiles(t, out, "cpu"))
+	assert.Error(t, pprof.StartCPUProfile(io.Discard))
+
+	// removing the token stops it
+	configureQuietLoggers(t, "debug,dir="+out.dir)
+	assert.Eventually(t, func() bool {
+		if err := pprof.StartCPUProfile(io.Discard); err != nil {
+			return false
+		}
+		pprof.StopCPUProfile()
+		return true
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, running, profileFiles(t, out, "cpu"))
+}
@@ -0,0 +1,98 @@
+package env_logger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+const defaultProfileServerAddr = ":11111"
+
+// shutdownTimeout bounds the graceful shutdown of a server replaced by a reconfiguration
+const shutdownTimeout = 5 * time.Second
+
+var profileSrv *http.Server
+var profileSrvNetwork, profileSrvAddr string
+var profileSrvListenAddr net.Addr
+var profileSrvMu sync.Mutex
+
+// startProfileServer starts the profile server on a tcp address or unix socket.
+// A running server on the same address is kept, one on a different address is shut down
+func startProfileServer(network, address string) {
+	profileSrvMu.Lock()
+	defer profileSrvMu.Unlock()
+
+	if profileSrv != nil {
+		if profileSrvNetwork == network && profileSrvAddr == address {
+			return
+		}
+		// shut down in the background, the reconfiguration might come from a request to the old server
+		old := profileSrv
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+			defer cancel()
+			old.Shutdown(ctx)
+		}()
+		profileSrv, profileSrvListenAddr = nil, nil
+	}
+
+	if network == "unix" {
+		// remove a stale socket of a previous run
+		if info, err := os.Stat(address); err == nil && info.Mode()&os.ModeSocket != 0 {
+			os.Remove(address)
+		}
+	}
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		Errorf("profileserver could not listen on %s: %v", address, err)
+		return
+	}
+
+	srv := &http.Server{Handler: AdminHandler()}
+	profileSrv, profileSrvNetwork, profileSrvAddr = srv, network, address
+	profileSrvListenAddr = listener.Addr()
+	Warnf("profileserver started on %s", listener.Addr())
+	go func() {
+		if err := srv.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			Error(err)
+		}
+	}()
+}
+
+// StopProfileServer gracefully shuts down the profile server, it can be started again by a new log config
+func StopProfileServer(ctx context.Context) error {
+	profileSrvMu.Lock()
+	srv := profileSrv
+	profileSrv, profileSrvListenAddr = nil, nil
+	profileSrvMu.Unlock()
+
+	if srv == nil {
+		return nil
+	}
+	return srv.Shutdown(ctx)
+}
+
+// AutoStartProfileServer starts the profile server on all interfaces with the given port, unless it is running already
+func AutoStartProfileServer(port uint16) {
+	if port == 0 {
+		port = 11111
+	}
+	profileSrvMu.Lock()
+	running := profileSrv != nil
+	profileSrvMu.Unlock()
+	if !running {
+		startProfileServer("tcp", fmt.Sprintf(":%d", port))
+	}
+}
+
+// profileServerListenAddr returns the address the profile server listens on, nil if it is not running
+func profileServerListenAddr() net.Addr {
+	profileSrvMu.Lock()
+	defer profileSrvMu.Unlock()
+	return profileSrvListenAddr
+}
@@ -0,0 +1,101 @@
+package env_logger
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func stopProfileServer(t *testing.T) {
+	t.Cleanup(func() {
+		require.NoError(t, StopProfileServer(context.Background()))
+	})
+}
+
+func getStatus(client *http.Client, url string) (int, error) {
+	res, err := client.Get(url)
+	if err != nil {
+		return 0, err
+	}
+	res.Body.Close()
+	return res.StatusCode, nil
+}
+
+func TestProfileServerAddress(t *testing.T) {
+	configureQuietLoggers(t, "info,pp,ppaddr=127.0.0.1:0")
+	stopProfileServer(t)
+
+	addr := profileServerListenAddr()
+	require.NotNil(t, addr)
+	assert.Equal(t, "127.0.0.1", addr.(*net.TCPAddr).IP.String())
+	status, err := getStatus(http.DefaultClient, "http://"+addr.String()+"/loglevels")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+
+	// the same address in a new config keeps the running server
+	configureQuietLoggers(t, "debug,pp,ppaddr=127.0.0.1:0")
+	assert.Equal(t, addr, profileServerListenAddr())
+}
+
+func TestProfileServerPortKeepsHost(t *testing.T) {
+	configureQuietLoggers(t, "info,pp,ppaddr=127.0.0.1:1,ppport=0")
+	stopProfileServer(t)
+
+	addr := profileServerListenAddr()
+	require.NotNil(t, addr)
+	assert.Equal(t, "127.0.0.1", addr.(*net.TCPAddr).IP.String())
+	assert.NotEqual(t, 1, addr.(*net.TCPAddr).Port)
+}
+
+func TestProfileServerRestartsOnNewAddress(t *testing.T) {
+	configureQuietLoggers(t, "info,pp,ppaddr=127.0.0.1:0")
+	stopProfileServer(t)
+	first := profileServerListenAddr()
+	require.NotNil(t, first)
+
+	dir, err := os.MkdirTemp("", "profileserver")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "log.sock")
+	configureQuietLoggers(t, "info,pp,ppsock="+socket)
+	assert.Equal(t, socket, profileServerListenAddr().String())
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+		},
+	}}
+	status, err := getStatus(client, "http://unix/loglevels")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+
+	// the old server is shut down in the background
+	assert.Eventually(t, func() bool {
+		_, err := getStatus(&http.Client{Transport: &http.Transport{DisableKeepAlives: true}}, "http://"+first.String()+"/loglevels")
+		return err != nil
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestStopProfileServer(t *testing.T) {
+	configureQuietLoggers(t, "info,pp,ppaddr=127.0.0.1:0")
+	addr := profileServerListenAddr()
+	require.NotNil(t, addr)
+
+	require.NoError(t, StopProfileServer(context.Background()))
+	assert.Nil(t, profileServerListenAddr())
+	_, err := getStatus(&http.Client{Transport: &http.Transport{DisableKeepAlives: true}}, "http://"+addr.String()+"/loglevels")
+	assert.Error(t, err)
+	assert.NoError(t, StopProfileServer(context.Background()))
+
+	// a new config starts it again
+	configureQuietLoggers(t, "info,pp,ppaddr=127.0.0.1:0")
+	stopProfileServer(t)
+	assert.NotNil(t, profileServerListenAddr())
+}
@@ -0,0 +1,229 @@
+package env_logger
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	logrus "github.com/sirupsen/logrus"
+)
+
+// defaultRingSize is used when ringall or ringdump are set without ring=<size>
+const defaultRingSize = 1000
+
+// levelGate lets a logger run at trace level for the ring buffer, while only entries up to level reach the output.
+// The level is atomic as SetLevel changes it while other goroutines are logging
+type levelGate struct {
+	logrus.Formatter
+	level atomic.Uint32
+
+	// pending holds suppressed entries dumped by the ring buffer, they are written in front of the next entry of this logger
+	pendingMu sync.Mutex
+	pending   []byte
+}
+
+func newLevelGate(formatter logrus.Formatter, level logrus.Level) *levelGate {
+	gate := &levelGate{Formatter: formatter}
+	gate.level.Store(uint32(level))
+	return gate
+}
+
+func (g *levelGate) getLevel() logrus.Level {
+	return logrus.Level(g.level.Load())
+}
+
+// Format returns nil for suppressed entries. logrus still calls Out.Write with the empty result, gatedOutput drops it
+func (g *levelGate) Format(entry *logrus.Entry) ([]byte, error) {
+	// Format runs under the logger mutex, writing the dump here keeps it from interleaving with other entries
+	g.pendingMu.Lock()
+	pending := g.pending
+	g.pending = nil
+	g.pendingMu.Unlock()
+
+	if entry.Level > g.getLevel() {
+		return pending, nil
+	}
+	serialized, err := g.Formatter.Format(entry)
+	if err != nil {
+		return pending, err
+	}
+	return append(pending, serialized...), nil
+}
+
+func (g *levelGate) addPending(serialized []byte) {
+	g.pendingMu.Lock()
+	defer g.pendingMu.Unlock()
+	g.pending = append(g.pending, serialized...)
+}
+
+// gatedOutput keeps the empty writes of suppressed entries away from the output of a gated logger
+type gatedOutput struct {
+	io.Writer
+}
+
+func (o *gatedOutput) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	return o.Writer.Write(p)
+}
+
+// Unwrap returns the output of the logger, formatters use it to detect terminals
+func (o *gatedOutput) Unwrap() io.Writer {
+	return o.Writer
+}
+
+func gateLogger(logger *logrus.Logger) {
+	if _, ok := logger.Formatter.(*levelGate); ok {
+		return
+	}
+	if text, ok := logger.Formatter.(*logrus.TextFormatter); ok {
+		// the TextFormatter only detects terminals on *os.File, let it do that once before the output is wrapped
+		text.Format(&logrus.Entry{Logger: logger})
+	}
+	logger.Formatter = newLevelGate(logger.Formatter, logger.GetLevel())
+	logger.SetOutput(&gatedOutput{Writer: logger.Out})
+	logger.SetLevel(logrus.TraceLevel)
+}
+
+func ungateLogger(logger *logrus.Logger) {
+	if gate, ok := logger.Formatter.(*levelGate); ok {
+		logger.Formatter = gate.Formatter
+		logger.SetLevel(gate.getLevel())
+	}
+	if out, ok := logger.Out.(*gatedOutput); ok {
+		logger.SetOutput(out.Writer)
+	}
+}
+
+// effectiveLevel returns the level entries have to reach to be written by logger
+func effectiveLevel(logger *logrus.Logger) logrus.Level {
+	if gate, ok := logger.Formatter.(*levelGate); ok {
+		return gate.getLevel()
+	}
+	return logger.GetLevel()
+}
+
+func setEffectiveLevel(logger *logrus.Logger, level logrus.Level) {
+	if gate, ok := logger.Formatter.(*levelGate); ok {
+		gate.level.Store(uint32(level))
+		return
+	}
+	logger.SetLevel(level)
+}
+
+type ringBuffer struct {
+	mu      sync.Mutex
+	records []*LogRecord
+	next    int
+	full    bool
+	dump    bool
+	remove  func()
+}
+
+var ring *ringBuffer
+var ringMu sync.Mutex
+
+// configureRing sets up the ring buffer, size 0 disables it. Records are kept when the size does not change
+func configureRing(size int, dump bool) {
+	ringMu.Lock()
+	defer ringMu.Unlock()
+
+	if ring != nil && (size == 0 || len(ring.records) != size) {
+		ring.remove()
+		ring = nil
+	}
+	if size == 0 {
+		return
+	}
+	if ring == nil {
+		ring = &ringBuffer{records: make([]*LogRecord, size)}
+		ring.remove = addRecordConsumer(ring.add)
+	}
+	ring.mu.Lock()
+	ring.dump = dump
+	ring.mu.Unlock()
+}
+
+func (b *ringBuffer) add(record *LogRecord) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.dump && record.level <= logrus.ErrorLevel {
+		b.dumpSuppressed(record.logger)
+	}
+
+	b.records[b.next] = record
+	b.next = (b.next + 1) % len(b.records)
+	if b.next == 0 {
+		b.full = true
+	}
+}
+
+// ordered returns the buffered records oldest first, b.mu has to be held
+func (b *ringBuffer) ordered() []*LogRecord {
+	if !b.full {
+		return b.records[:b.next]
+	}
+	return append(append([]*LogRecord{}, b.records[b.next:]...), b.records[:b.next]...)
+}
+
+// dumpSuppressed hands the buffered entries that were below the configured level to the gate of the logger about to write the error.
+// Hooks run without the logger mutex, so the dump is written by the gate together with the error entry
+func (b *ringBuffer) dumpSuppressed(target *logrus.Logger) {
+	if target == nil {
+		return
+	}
+	targetGate, ok := target.Formatter.(*levelGate)
+	if !ok {
+		return
+	}
+	for _, record := range b.ordered() {
+		if !record.suppressed || record.dumped || record.logger == nil {
+			continue
+		}
+		record.dumped = true
+
+		gate, ok := record.logger.Formatter.(*levelGate)
+		if !ok {
+			continue
+		}
+		data := make(logrus.Fields, len(record.Fields)+1)
+		for key, value := range record.Fields {
+			data[key] = value
+		}
+		if record.Module != "" {
+			data["module"] = record.Module
+		}
+		entry := &logrus.Entry{Logger: record.logger, Data: data, Time: record.Time, Level: record.level, Message: record.Message}
+		if serialized, err := gate.Formatter.Format(entry); err == nil {
+			targetGate.addPending(serialized)
+		}
+	}
+}
+
+// RecentLogs returns the entries kept in the ring buffer, oldest first. The ring buffer is enabled with ring=<size> in the log config
+func RecentLogs() []LogRecord {
+	ringMu.Lock()
+	b := ring
+	ringMu.Unlock()
+	if b == nil {
+		return []LogRecord{}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	records := b.ordered()
+	list := make([]LogRecord, len(records))
+	for i, record := range records {
+		list[i] = *record
+	}
+	return list
+}
+
+func handleRecent(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(RecentLogs())
+}
@@ -0,0 +1,61 @@
+package env_logger
+
+import (
+	"context"
+	"os"
+	"runtime"
+	"time"
+
+	logrus "github.com/sirupsen/logrus"
+)
+
+// defaultRuntimeStatsInterval is used by grl without an interval
+const defaultRuntimeStatsInterval = time.Second
+
+// logGoRoutines logs goroutine count and runtime stats under the module runtime, so it can get its own level
+func logGoRoutines(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			GetLoggerForPrefix("runtime").WithFields(runtimeStats()).Info("runtime stats")
+		}
+	}
+}
+
+func runtimeStats() logrus.Fields {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	fields := logrus.Fields{
+		"routines":          runtime.NumGoroutine(),
+		"heap_inuse":        mem.HeapInuse,
+		"heap_alloc":        mem.HeapAlloc,
+		"sys":               mem.Sys,
+		"num_gc":            mem.NumGC,
+		"gc_pause_total_ms": durationMs(time.Duration(mem.PauseTotalNs)),
+		"cgo_calls":         runtime.NumCgoCall(),
+	}
+	if mem.NumGC > 0 {
+		fields["gc_pause_last_ms"] = durationMs(time.Duration(mem.PauseNs[(mem.NumGC+255)%256]))
+	}
+	if fds, ok := openFileDescriptors(); ok {
+		fields["open_fds"] = fds
+	}
+	return fields
+}
+
+// openFileDescriptors counts the entries of the fd directory, where the platform has one
+func openFileDescriptors() (int, bool) {
+	for _, dir := range []string{"/proc/self/fd", "/dev/fd"} {
+		entries, err := os.ReadDir(dir)
+		if err == nil {
+			// reading the directory holds one descriptor itself
+			return len(entries) - 1, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,79 @@
+package env_logger
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"runtime"
+	"testing"
+	"time"
+
+	logrus "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRuntimeStats(t *testing.T) {
+	runtime.GC()
+	fields := runtimeStats()
+
+	assert.Greater(t, fields["routines"], 0)
+	assert.Greater(t, fields["heap_inuse"], uint64(0))
+	assert.Greater(t, fields["sys"], uint64(0))
+	assert.Greater(t, fields["num_gc"], uint32(0))
+	assert.Contains(t, fields, "gc_pause_last_ms")
+	assert.Contains(t, fields, "gc_pause_total_ms")
+	assert.Contains(t, fields, "cgo_calls")
+}
+
+func TestOpenFileDescriptors(t *testing.T) {
+	before, ok := openFileDescriptors()
+	if !ok {
+		t.Skip("no fd directory on this platform")
+	}
+	// other tests may leave connections behind that close meanwhile, so only check a clear increase
+	for i := 0; i < 20; i++ {
+		f, err := os.Open(os.Args[0])
+		require.NoError(t, err)
+		defer f.Close()
+	}
+
+	after, _ := openFileDescriptors()
+	assert.GreaterOrEqual(t, after-before, 15)
+}
+
+// firstLineWriter keeps the first entry written to it
+type firstLineWriter chan []byte
+
+func (w firstLineWriter) Write(p []byte) (int, error) {
+	select {
+	case w <- append([]byte(nil), p...):
+	default:
+	}
+	return len(p), nil
+}
+
+func TestLogGoRoutinesUsesRuntimeModule(t *testing.T) {
+	out := make(firstLineWriter, 1)
+	logger := logrus.New()
+	logger.Out = out
+	logger.Formatter = &logrus.JSONFormatter{}
+	ConfigureAllLoggers(logger, "info")
+	defer SetGlobalDebugConfig("")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go logGoRoutines(ctx, time.Millisecond)
+
+	var line []byte
+	select {
+	case line = <-out:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no runtime stats logged")
+	}
+	var fields map[string]interface{}
+	require.NoError(t, json.Unmarshal(line, &fields))
+	assert.Equal(t, "runtime", fields["module"])
+	assert.Equal(t, "runtime stats", fields["msg"])
+	assert.Contains(t, fields, "heap_inuse")
+}
@@ -0,0 +1,96 @@
+package env_logger
+
+import (
+	"context"
+	"sync"
+
+	logrus "github.com/sirupsen/logrus"
+)
+
+type spanContextKey struct{}
+
+// Span measures a named section of work, spans nest through the context passed to StartSpan
+type Span struct {
+	name   string
+	parent *Span
+	depth  int
+	logger *logrus.Entry
+	end    func()
+	once   sync.Once
+}
+
+// StartSpan starts a span as child of the span in ctx, End logs its duration.
+// Log lines created with WithContext(ctx) on the returned context carry the span fields
+func StartSpan(ctx context.Context, name string, opts ...TimeOption) (context.Context, *Span) {
+	return startSpan(ctx, getLogger(nil), name, opts)
+}
+
+func (e *Entry) StartSpan(ctx context.Context, name string, opts ...TimeOption) (context.Context, *Span) {
+	return startSpan(ctx, getLogger(e), name, opts)
+}
+
+func startSpan(ctx context.Context, logger *logrus.Entry, name string, opts []TimeOption) (context.Context, *Span) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	span := &Span{name: name}
+	if parent := SpanFromContext(ctx); parent != nil {
+		span.parent = parent
+		span.depth = parent.depth + 1
+	}
+	ctx = context.WithValue(ctx, spanContextKey{}, span)
+
+	span.logger = logger.WithContext(ctx).WithFields(span.fields())
+	span.logger.Debug(name)
+	span.end = scopedTimer(span.logger, name, opts)
+	return ctx, span
+}
+
+// SpanFromContext returns the innermost span stored in ctx or nil
+func SpanFromContext(ctx context.Context) *Span {
+	if ctx == nil {
+		return nil
+	}
+	span, _ := ctx.Value(spanContextKey{}).(*Span)
+	return span
+}
+
+func (s *Span) fields() logrus.Fields {
+	fields := logrus.Fields{"span": s.name, "span_depth": s.depth}
+	if s.parent != nil {
+		fields["parent_span"] = s.parent.name
+	}
+	return fields
+}
+
+// Name returns the name the span was started with
+func (s *Span) Name() string {
+	return s.name
+}
+
+// Entry returns a log entry carrying the span fields
+func (s *Span) Entry() *Entry {
+	return (*Entry)(s.logger)
+}
+
+// End logs the duration of the span, only the first call has an effect
+func (s *Span) End() {
+	s.once.Do(s.end)
+}
+
+// WithContext returns an entry carrying ctx and the fields of the span stored in it
+func WithContext(ctx context.Context) *Entry {
+	return withSpanContext(getLogger(nil), ctx)
+}
+
+func (e *Entry) WithContext(ctx context.Context) *Entry {
+	return withSpanContext(getLogger(e), ctx)
+}
+
+func withSpanContext(logger *logrus.Entry, ctx context.Context) *Entry {
+	logger = logger.WithContext(ctx)
+	if span := SpanFromContext(ctx); span != nil {
+		logger = logger.WithFields(span.fields())
+	}
+	return (*Entry)(logger)
+}
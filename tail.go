@@ -0,0 +1,126 @@
+package env_logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	logrus "github.com/sirupsen/logrus"
+)
+
+// tailBufferSize is the number of records a tail client may lag behind before it gets dropped
+const tailBufferSize = 256
+
+type tailFilter struct {
+	modules  map[string]bool
+	minLevel logrus.Level
+	fields   map[string]string
+}
+
+func parseTailFilter(r *http.Request) (*tailFilter, error) {
+	query := r.URL.Query()
+	filter := &tailFilter{minLevel: logrus.TraceLevel, fields: make(map[string]string)}
+
+	if modules := query.Get("module"); modules != "" {
+		filter.modules = make(map[string]bool)
+		for _, module := range strings.Split(modules, ",") {
+			filter.modules[module] = true
+		}
+	}
+	if level := query.Get("level"); level != "" {
+		l, err := logrus.ParseLevel(level)
+		if err != nil {
+			return nil, err
+		}
+		filter.minLevel = l
+	}
+	for _, field := range query["field"] {
+		tmp := strings.SplitN(field, ":", 2)
+		if len(tmp) != 2 {
+			return nil, fmt.Errorf("field filter '%s' has to be formatted as key:value", field)
+		}
+		filter.fields[tmp[0]] = tmp[1]
+	}
+	return filter, nil
+}
+
+func (f *tailFilter) matches(record *LogRecord) bool {
+	if record.suppressed || record.level > f.minLevel {
+		return false
+	}
+	if f.modules != nil && !f.modules[record.Module] {
+		return false
+	}
+	for key, value := range f.fields {
+		if fmt.Sprint(record.Fields[key]) != value {
+			return false
+		}
+	}
+	return true
+}
+
+// handleTail streams log entries as server sent events, or as NDJSON with ?format=ndjson
+// Filters: module=a,b level=<min level> field=key:value (repeatable)
+func handleTail(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	filter, err := parseTailFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ndjson := r.URL.Query().Get("format") == "ndjson"
+
+	records := make(chan *LogRecord, tailBufferSize)
+	dropped := make(chan struct{})
+	var dropOnce sync.Once
+	remove := addRecordConsumer(func(record *LogRecord) {
+		if !filter.matches(record) {
+			return
+		}
+		select {
+		case records <- record:
+		default:
+			// never block logging on a slow client
+			dropOnce.Do(func() { close(dropped) })
+		}
+	})
+	defer remove()
+
+	if ndjson {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	} else {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-dropped:
+			return
+		case record := <-records:
+			data, err := json.Marshal(record)
+			if err != nil {
+				continue
+			}
+			if ndjson {
+				_, err = fmt.Fprintf(w, "%s\n", data)
+			} else {
+				_, err = fmt.Fprintf(w, "data: %s\n\n", data)
+			}
+			if err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
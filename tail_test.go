@@ -0,0 +1,130 @@
+package env_logger
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	logrus "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// configureQuietLoggers logs everything into a buffer for the duration of a test
+func configureQuietLoggers(t *testing.T, debugConfig string) {
+	logger := logrus.New()
+	logger.Out = &bytes.Buffer{}
+	ConfigureAllLoggers(logger, debugConfig)
+	t.Cleanup(func() { SetGlobalDebugConfig("") })
+}
+
+func recordConsumerCount() int {
+	recordConsumersMu.RLock()
+	defer recordConsumersMu.RUnlock()
+	return len(recordConsumers)
+}
+
+func TestTailFilters(t *testing.T) {
+	configureQuietLoggers(t, "trace")
+	srv := httptest.NewServer(http.HandlerFunc(handleTail))
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL + "/tail?format=ndjson&module=Tailing&level=warn&field=user:ada")
+	require.NoError(t, err)
+	defer res.Body.Close()
+	assert.Equal(t, "application/x-ndjson", res.Header.Get("Content-Type"))
+
+	log := GetLoggerForPrefix("Tailing")
+	log.WithField("user", "ada").Info("level too low")
+	GetLoggerForPrefix("Other").WithField("user", "ada").Warn("other module")
+	log.WithField("user", "bob").Warn("other user")
+	log.WithField("user", "ada").Warn("match")
+
+	line, err := bufio.NewReader(res.Body).ReadBytes('\n')
+	require.NoError(t, err)
+	var record LogRecord
+	require.NoError(t, json.Unmarshal(line, &record))
+	assert.Equal(t, "match", record.Message)
+	assert.Equal(t, "Tailing", record.Module)
+	assert.Equal(t, "warning", record.Level)
+	assert.Equal(t, "ada", record.Fields["user"])
+}
+
+func TestTailServerSentEvents(t *testing.T) {
+	configureQuietLoggers(t, "info")
+	srv := httptest.NewServer(http.HandlerFunc(handleTail))
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL + "/tail?module=Tailing")
+	require.NoError(t, err)
+	defer res.Body.Close()
+	assert.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+
+	GetLoggerForPrefix("Tailing").Debug("suppressed")
+	GetLoggerForPrefix("Tailing").Info("streamed")
+
+	reader := bufio.NewReader(res.Body)
+	line, err := reader.ReadString('\n')
+	require.NoError(t, err)
+	assert.Contains(t, line, `"msg":"streamed"`)
+	assert.Regexp(t, `^data: \{.*\}\n$`, line)
+	empty, err := reader.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "\n", empty)
+}
+
+func TestTailRejectsInvalidFilter(t *testing.T) {
+	for _, query := range []string{"level=loud", "field=nocolon"} {
+		rec := httptest.NewRecorder()
+		handleTail(rec, httptest.NewRequest(http.MethodGet, "/tail?"+query, nil))
+		assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+	}
+}
+
+// blockingWriter stalls every write of the body until release is closed
+type blockingWriter struct {
+	header  http.Header
+	release chan struct{}
+}
+
+func (w *blockingWriter) Header() http.Header { return w.header }
+func (w *blockingWriter) WriteHeader(int)     {}
+func (w *blockingWriter) Flush()              {}
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	<-w.release
+	return len(p), nil
+}
+
+func TestTailDropsSlowClient(t *testing.T) {
+	configureQuietLoggers(t, "info")
+	consumers := recordConsumerCount()
+
+	w := &blockingWriter{header: make(http.Header), release: make(chan struct{})}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		handleTail(w, httptest.NewRequest(http.MethodGet, "/tail", nil).WithContext(ctx))
+		close(done)
+	}()
+	require.Eventually(t, func() bool { return recordConsumerCount() == consumers+1 }, 5*time.Second, time.Millisecond)
+
+	// logging must not block while the client does not read
+	log := GetLoggerForPrefix("Tailing")
+	for i := 0; i < tailBufferSize+10; i++ {
+		log.Info("flood")
+	}
+	close(w.release)
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("slow client was not dropped")
+	}
+	assert.Equal(t, consumers, recordConsumerCount())
+}
@@ -0,0 +1,72 @@
+package env_logger
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	logrus "github.com/sirupsen/logrus"
+)
+
+// TimerLeak describes a timer started with Timer that has not been ended yet
+type TimerLeak struct {
+	Key     string
+	Started time.Time
+	Age     time.Duration
+	Module  string
+	File    string
+	Line    int
+}
+
+// TimerLeaks returns all running timers that have been started longer than olderThan ago, oldest first
+func TimerLeaks(olderThan time.Duration) []TimerLeak {
+	timersMu.Lock()
+	defer timersMu.Unlock()
+	return collectTimerLeaks(olderThan, false)
+}
+
+// collectTimerLeaks expects timersMu to be held, if markReported is set only leaks not reported before are returned
+func collectTimerLeaks(olderThan time.Duration, markReported bool) []TimerLeak {
+	now := time.Now()
+	leaks := make([]TimerLeak, 0)
+	for key, t := range timers {
+		age := now.Sub(t.start)
+		if age < olderThan || (markReported && t.reported) {
+			continue
+		}
+		if markReported {
+			t.reported = true
+		}
+		leaks = append(leaks, TimerLeak{Key: key, Started: t.start, Age: age, Module: t.module, File: t.file, Line: t.line})
+	}
+	sort.Slice(leaks, func(i, j int) bool {
+		return leaks[i].Started.Before(leaks[j].Started)
+	})
+	return leaks
+}
+
+func logTimerLeaks(ctx context.Context, threshold time.Duration) {
+	t := time.NewTicker(threshold)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			timersMu.Lock()
+			leaks := collectTimerLeaks(threshold, true)
+			timersMu.Unlock()
+
+			log := GetLoggerForPrefix("timers")
+			for _, leak := range leaks {
+				log.WithFields(logrus.Fields{
+					"timer":  leak.Key,
+					"age":    leak.Age.Round(time.Millisecond).String(),
+					"origin": leak.Module,
+					"site":   fmt.Sprintf("%s:%d", leak.File, leak.Line),
+				}).Warn("timer was started but never ended")
+			}
+		}
+	}
+}
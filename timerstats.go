@@ -0,0 +1,184 @@
+package env_logger
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	logrus "github.com/sirupsen/logrus"
+)
+
+// Buckets grow by a factor of 2^(1/4) starting at one microsecond, the last bucket catches everything above ~70 minutes
+const (
+	histogramBuckets       = 128
+	histogramBucketsPerPow = 4
+	histogramBase          = time.Microsecond
+)
+
+// TimerStat is the aggregated view of all measurements of a named timer
+type TimerStat struct {
+	Count uint64
+	Min   time.Duration
+	Max   time.Duration
+	Mean  time.Duration
+	P50   time.Duration
+	P95   time.Duration
+	P99   time.Duration
+}
+
+type timerHistogram struct {
+	count   uint64
+	sum     time.Duration
+	min     time.Duration
+	max     time.Duration
+	buckets [histogramBuckets]uint64
+}
+
+// maxTimerStats caps the number of distinct timer names with statistics, each one holds about 1KB of buckets.
+// Measurements of further names are aggregated under overflowTimer, so timer names should not contain ids
+const maxTimerStats = 1000
+
+const overflowTimer = "(other)"
+
+var timerStats = make(map[string]*timerHistogram)
+var timerStatsMu sync.Mutex
+var timerStatsOverflowed bool
+
+func bucketFor(d time.Duration) int {
+	if d <= histogramBase {
+		return 0
+	}
+	i := int(math.Ceil(math.Log2(float64(d)/float64(histogramBase)) * histogramBucketsPerPow))
+	if i >= histogramBuckets {
+		return histogramBuckets - 1
+	}
+	return i
+}
+
+func bucketUpperBound(i int) time.Duration {
+	return time.Duration(float64(histogramBase) * math.Pow(2, float64(i)/histogramBucketsPerPow))
+}
+
+func (h *timerHistogram) add(d time.Duration) {
+	if h.count == 0 || d < h.min {
+		h.min = d
+	}
+	if d > h.max {
+		h.max = d
+	}
+	h.count++
+	h.sum += d
+	h.buckets[bucketFor(d)]++
+}
+
+// quantile returns the upper bound of the bucket holding the q-th measurement, clamped to the observed range
+func (h *timerHistogram) quantile(q float64) time.Duration {
+	rank := uint64(math.Ceil(q * float64(h.count)))
+	var seen uint64
+	for i, n := range h.buckets {
+		seen += n
+		if seen >= rank && n != 0 {
+			d := bucketUpperBound(i)
+			if d > h.max {
+				return h.max
+			}
+			if d < h.min {
+				return h.min
+			}
+			return d
+		}
+	}
+	return h.max
+}
+
+func (h *timerHistogram) stat() TimerStat {
+	return TimerStat{
+		Count: h.count,
+		Min:   h.min,
+		Max:   h.max,
+		Mean:  h.sum / time.Duration(h.count),
+		P50:   h.quantile(0.50),
+		P95:   h.quantile(0.95),
+		P99:   h.quantile(0.99),
+	}
+}
+
+func recordTimer(name string, d time.Duration) {
+	timerStatsMu.Lock()
+	h, ok := timerStats[name]
+	warn := false
+	if !ok && len(timerStats) >= maxTimerStats {
+		warn = !timerStatsOverflowed
+		timerStatsOverflowed = true
+		name = overflowTimer
+		h, ok = timerStats[name]
+	}
+	if !ok {
+		h = &timerHistogram{}
+		timerStats[name] = h
+	}
+	h.add(d)
+	timerStatsMu.Unlock()
+
+	if warn {
+		GetLoggerForPrefix("timers").Warnf("more than %d timer names, further names are aggregated as %s", maxTimerStats, overflowTimer)
+	}
+}
+
+// TimerStats returns the aggregated measurements of all named timers ended so far
+func TimerStats() map[string]TimerStat {
+	timerStatsMu.Lock()
+	defer timerStatsMu.Unlock()
+	stats := make(map[string]TimerStat, len(timerStats))
+	for name, h := range timerStats {
+		stats[name] = h.stat()
+	}
+	return stats
+}
+
+// ResetTimerStats drops all aggregated timer measurements
+func ResetTimerStats() {
+	timerStatsMu.Lock()
+	defer timerStatsMu.Unlock()
+	timerStats = make(map[string]*timerHistogram)
+	timerStatsOverflowed = false
+}
+
+func logTimerStats(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			stats := TimerStats()
+			names := make([]string, 0, len(stats))
+			for name := range stats {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+
+			log := GetLoggerForPrefix("timers")
+			for _, name := range names {
+				s := stats[name]
+				log.WithFields(logrus.Fields{
+					"timer":   name,
+					"count":   s.Count,
+					"min_ms":  durationMs(s.Min),
+					"max_ms":  durationMs(s.Max),
+					"mean_ms": durationMs(s.Mean),
+					"p50_ms":  durationMs(s.P50),
+					"p95_ms":  durationMs(s.P95),
+					"p99_ms":  durationMs(s.P99),
+				}).Info("timer stats")
+			}
+		}
+	}
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
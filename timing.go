@@ -4,27 +4,67 @@ import (
 	"fmt"
 	"sync"
 	"time"
+
+	logrus "github.com/sirupsen/logrus"
 )
 
-var timers map[string]time.Time = make(map[string]time.Time)
+// maxTimers caps the number of running timers, the oldest one is dropped when a new one would exceed it
+const maxTimers = 10000
+
+type runningTimer struct {
+	start    time.Time
+	module   string
+	file     string
+	line     int
+	reported bool
+}
+
+var timers map[string]*runningTimer = make(map[string]*runningTimer)
 var timersMu sync.Mutex
 
 func Timer(idkey string) string {
+	return startTimer(idkey)
+}
+
+// startTimer has to be called directly by the exported Timer functions for the call site to be correct
+func startTimer(idkey string) string {
+	module, file, line := getCaller(4)
+
 	timersMu.Lock()
-	defer timersMu.Unlock()
-	timers[idkey] = time.Now()
+	var dropped string
+	if _, ok := timers[idkey]; !ok && len(timers) >= maxTimers {
+		dropped = oldestTimer()
+		delete(timers, dropped)
+	}
+	timers[idkey] = &runningTimer{start: time.Now(), module: module, file: file, line: line}
+	timersMu.Unlock()
 
+	if dropped != "" {
+		GetLoggerForPrefix("timers").WithField("timer", dropped).Warnf("more than %d timers running, dropped the oldest one", maxTimers)
+	}
 	return idkey
 }
 
+func oldestTimer() string {
+	var oldest string
+	var oldestStart time.Time
+	for key, t := range timers {
+		if oldest == "" || t.start.Before(oldestStart) {
+			oldest, oldestStart = key, t.start
+		}
+	}
+	return oldest
+}
+
 // Print time since the last call to the Time function with the same name
 func TimerEnd(idkey string) string {
 	timersMu.Lock()
 	defer timersMu.Unlock()
 	if t, ok := timers[idkey]; ok {
-		res := fmt.Sprint(time.Since(t))
+		elapsed := time.Since(t.start)
 		delete(timers, idkey)
-		return res
+		recordTimer(idkey, elapsed)
+		return fmt.Sprint(elapsed)
 	}
 
 	return "unknown timer"
@@ -35,8 +75,9 @@ func TimerEndValue(idkey string) (time.Duration, error) {
 	timersMu.Lock()
 	defer timersMu.Unlock()
 	if t, ok := timers[idkey]; ok {
-		res := time.Since(t)
+		res := time.Since(t.start)
 		delete(timers, idkey)
+		recordTimer(idkey, res)
 		return res, nil
 	}
 
@@ -44,9 +85,58 @@ func TimerEndValue(idkey string) (time.Duration, error) {
 }
 
 func (e *Entry) Timer(idkey string) string {
-	return Timer(idkey)
+	return startTimer(idkey)
 }
 
 func (e *Entry) TimerEnd(idkey string) string {
 	return TimerEnd(idkey)
 }
+
+// TimeOption configures a scoped timer created by Time
+type TimeOption func(*timeConfig)
+
+type timeConfig struct {
+	level     logrus.Level
+	threshold time.Duration
+}
+
+// TimeLevel sets the level the elapsed time is logged at, defaults to info
+func TimeLevel(level logrus.Level) TimeOption {
+	return func(c *timeConfig) {
+		c.level = level
+	}
+}
+
+// TimeThreshold only logs the elapsed time if it took at least the given duration
+func TimeThreshold(threshold time.Duration) TimeOption {
+	return func(c *timeConfig) {
+		c.threshold = threshold
+	}
+}
+
+// Time starts a scoped timer, the returned function logs the elapsed time as duration_ms, use as defer log.Time("load config")()
+func Time(name string, opts ...TimeOption) func() {
+	return scopedTimer(getLogger(nil), name, opts)
+}
+
+// TimeScope is the Entry version of Time, Entry already carries the Time field of the logrus entry
+func (e *Entry) TimeScope(name string, opts ...TimeOption) func() {
+	return scopedTimer(getLogger(e), name, opts)
+}
+
+func scopedTimer(logger *logrus.Entry, name string, opts []TimeOption) func() {
+	config := timeConfig{level: logrus.InfoLevel}
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	start := time.Now()
+	return func() {
+		elapsed := time.Since(start)
+		recordTimer(name, elapsed)
+		if elapsed < config.threshold {
+			return
+		}
+		logger.WithField("duration_ms", durationMs(elapsed)).Log(config.level, name)
+	}
+}
@@ -0,0 +1,171 @@
+package env_logger
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"runtime/trace"
+	"sync"
+	"time"
+)
+
+var traceMu sync.Mutex
+var traceFile *os.File
+var traceTimer *time.Timer
+var traceStopAnnotating func()
+
+// traceID identifies the running trace, so the timer of an earlier trace can not stop a newer one
+var traceID uint64
+
+// defaultTraceDuration and maxTraceDuration bound traces started over http, an unbounded trace would grow its file forever
+const (
+	defaultTraceDuration = 5 * time.Second
+	maxTraceDuration     = 10 * time.Minute
+)
+
+// configuredTrace is the trace duration of the previous log config, guarded by traceMu
+var configuredTrace time.Duration
+
+// StartTrace starts the execution tracer writing to a file in the profile directory (dir in the log config).
+// While it runs every log entry is added to the trace as event. A duration of 0 traces until StopTrace is called
+func StartTrace(duration time.Duration) (string, error) {
+	traceMu.Lock()
+	defer traceMu.Unlock()
+	if traceFile != nil {
+		return "", fmt.Errorf("trace to %s is already running", traceFile.Name())
+	}
+
+	f, err := currentProfileOutput().create("trace", ".out")
+	if err != nil {
+		return "", err
+	}
+	if err := trace.Start(f); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	traceFile = f
+	traceID++
+	traceStopAnnotating = addRecordConsumer(annotateTrace)
+	if duration > 0 {
+		id := traceID
+		traceTimer = time.AfterFunc(duration, func() {
+			expireTrace(id)
+		})
+	}
+	return f.Name(), nil
+}
+
+// startConfiguredTrace starts the trace of the trace= token, but only when the token is new or changed.
+// The token stays in the config, so level changes must not start another trace
+func startConfiguredTrace(duration time.Duration) {
+	traceMu.Lock()
+	changed := duration != configuredTrace
+	configuredTrace = duration
+	traceMu.Unlock()
+
+	if duration <= 0 || !changed {
+		return
+	}
+	if _, err := StartTrace(duration); err != nil {
+		GetLoggerForPrefix("profiles").Warnf("could not start trace: %v", err)
+	}
+}
+
+// expireTrace stops the trace with the given id when its duration is over
+func expireTrace(id uint64) {
+	traceMu.Lock()
+	if traceFile == nil || traceID != id {
+		traceMu.Unlock()
+		return
+	}
+	file, err := stopTraceLocked()
+	traceMu.Unlock()
+
+	if err == nil {
+		GetLoggerForPrefix("profiles").WithField("file", file).Info("execution trace written")
+	}
+}
+
+// StopTrace stops a running execution trace and returns the file it was written to
+func StopTrace() (string, error) {
+	traceMu.Lock()
+	defer traceMu.Unlock()
+	return stopTraceLocked()
+}
+
+func stopTraceLocked() (string, error) {
+	if traceFile == nil {
+		return "", fmt.Errorf("no trace running")
+	}
+	if traceTimer != nil {
+		traceTimer.Stop()
+		traceTimer = nil
+	}
+	traceStopAnnotating()
+	trace.Stop()
+
+	name := traceFile.Name()
+	err := traceFile.Close()
+	traceFile = nil
+	currentProfileOutput().prune("trace", ".out")
+	return name, err
+}
+
+// annotateTrace adds log entries as user log events to the execution trace
+func annotateTrace(record *LogRecord) {
+	if record.suppressed || !trace.IsEnabled() {
+		return
+	}
+	category := record.Module
+	if category == "" {
+		category = "log"
+	}
+	trace.Log(context.Background(), category, record.Level+": "+record.Message)
+}
+
+// handleTraceStart serves POST /trace/start?duration=5s, the duration defaults to 5s and is capped at 10m
+func handleTraceStart(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	duration := defaultTraceDuration
+	if param := r.URL.Query().Get("duration"); param != "" {
+		var err error
+		if duration, err = time.ParseDuration(param); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if duration <= 0 {
+			http.Error(w, "duration has to be positive", http.StatusBadRequest)
+			return
+		}
+	}
+	if duration > maxTraceDuration {
+		duration = maxTraceDuration
+	}
+	file, err := StartTrace(duration)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	fmt.Fprintf(w, "Trace started for %s: %s", duration, file)
+}
+
+// handleTraceStop serves POST /trace/stop
+func handleTraceStop(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	file, err := StopTrace()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	fmt.Fprintf(w, "Trace written: %s", file)
+}
@@ -0,0 +1,136 @@
+package env_logger
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func traceRunning() bool {
+	traceMu.Lock()
+	defer traceMu.Unlock()
+	return traceFile != nil
+}
+
+func configureTraceDir(t *testing.T, debugConfig string) profileOutput {
+	out := tempProfileOutput(t, 10)
+	configureQuietLoggers(t, debugConfig+",dir="+out.dir)
+	t.Cleanup(func() { StopTrace() })
+	return out
+}
+
+func TestStartStopTrace(t *testing.T) {
+	configureTraceDir(t, "info")
+
+	file, err := StartTrace(0)
+	require.NoError(t, err)
+	_, err = StartTrace(0)
+	assert.Error(t, err)
+
+	GetLoggerForPrefix("Testing").Info("annotated")
+	stopped, err := StopTrace()
+	require.NoError(t, err)
+	assert.Equal(t, file, stopped)
+	info, err := os.Stat(file)
+	require.NoError(t, err)
+	assert.NotZero(t, info.Size())
+
+	_, err = StopTrace()
+	assert.Error(t, err)
+}
+
+func TestTraceStopsAfterDuration(t *testing.T) {
+	configureTraceDir(t, "info")
+	written := make(chan string, 1)
+	remove := addRecordConsumer(func(record *LogRecord) {
+		if record.Message == "execution trace written" {
+			written <- record.Fields["file"].(string)
+		}
+	})
+	defer remove()
+
+	file, err := StartTrace(20 * time.Millisecond)
+	require.NoError(t, err)
+	select {
+	case stopped := <-written:
+		assert.Equal(t, file, stopped)
+	case <-time.After(5 * time.Second):
+		t.Fatal("trace was not stopped")
+	}
+	assert.False(t, traceRunning())
+}
+
+func TestStaleTraceTimerKeepsNewTrace(t *testing.T) {
+	configureTraceDir(t, "info")
+	_, err := StartTrace(time.Hour)
+	require.NoError(t, err)
+	traceMu.Lock()
+	first := traceID
+	traceMu.Unlock()
+	_, err = StopTrace()
+	require.NoError(t, err)
+
+	_, err = StartTrace(0)
+	require.NoError(t, err)
+	// the timer of the first trace fires late
+	expireTrace(first)
+	assert.True(t, traceRunning())
+}
+
+func TestTraceTokenStartsOnce(t *testing.T) {
+	out := configureTraceDir(t, "info,trace=1h")
+	require.True(t, traceRunning())
+	traceMu.Lock()
+	id := traceID
+	traceMu.Unlock()
+
+	// a level change keeps the token, the trace is neither restarted nor stopped
+	configureQuietLoggers(t, "debug,trace=1h,dir="+out.dir)
+	traceMu.Lock()
+	assert.Equal(t, id, traceID)
+	traceMu.Unlock()
+
+	_, err := StopTrace()
+	require.NoError(t, err)
+	configureQuietLoggers(t, "debug,trace=1h,dir="+out.dir)
+	assert.False(t, traceRunning())
+
+	// removing and adding the token again starts a new one
+	configureQuietLoggers(t, "debug,dir="+out.dir)
+	configureQuietLoggers(t, "debug,trace=1h,dir="+out.dir)
+	assert.True(t, traceRunning())
+	files, _ := filepath.Glob(out.pattern("trace", ".out"))
+	assert.Len(t, files, 2)
+}
+
+func TestTraceHandlers(t *testing.T) {
+	configureTraceDir(t, "info")
+	handler := AdminHandler()
+	serve := func(method, url string) int {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(method, url, nil))
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusMethodNotAllowed, serve(http.MethodGet, "/trace/start"))
+	assert.Equal(t, http.StatusBadRequest, serve(http.MethodPost, "/trace/start?duration=soon"))
+	assert.Equal(t, http.StatusBadRequest, serve(http.MethodPost, "/trace/start?duration=0s"))
+	assert.Equal(t, http.StatusOK, serve(http.MethodPost, "/trace/start?duration=1h"))
+	assert.Equal(t, http.StatusConflict, serve(http.MethodPost, "/trace/start"))
+	assert.Equal(t, http.StatusMethodNotAllowed, serve(http.MethodGet, "/trace/stop"))
+	assert.Equal(t, http.StatusOK, serve(http.MethodPost, "/trace/stop"))
+	assert.Equal(t, http.StatusConflict, serve(http.MethodPost, "/trace/stop"))
+
+	// without a duration the trace still ends on its own
+	assert.Equal(t, http.StatusOK, serve(http.MethodPost, "/trace/start"))
+	traceMu.Lock()
+	assert.NotNil(t, traceTimer)
+	traceMu.Unlock()
+	assert.Equal(t, http.StatusOK, serve(http.MethodPost, "/trace/stop"))
+}
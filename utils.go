@@ -1,11 +1,8 @@
 package env_logger
 
 import (
-	"context"
 	"encoding/json"
 	"fmt"
-	"runtime"
-	"time"
 )
 
 // Wrap an error, this is useful in combination with Should and Must
@@ -55,15 +52,3 @@ func Indent(arg interface{}) string {
 func (e *Entry) Indent(arg interface{}) string {
 	return Indent(arg)
 }
-
-func logGoRoutines(ctx context.Context) {
-	t := time.NewTicker(time.Second)
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-t.C:
-			Info("Routines: ", runtime.NumGoroutine())
-		}
-	}
-}
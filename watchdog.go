@@ -0,0 +1,108 @@
+package env_logger
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	logrus "github.com/sirupsen/logrus"
+)
+
+const (
+	watchdogInterval        = 2 * time.Second
+	defaultWatchdogCooldown = 10 * time.Minute
+)
+
+// lastWatchdogDump survives reconfigurations, so a new log config does not trigger another dump right away
+var lastWatchdogDump atomic.Int64
+
+type watchdogConfig struct {
+	heapLimit      uint64
+	goroutineLimit int
+	cooldown       time.Duration
+	profiles       profileOutput
+}
+
+// parseByteSize parses sizes like 512MB or 1GB (powers of 1024), plain numbers are bytes
+func parseByteSize(size string) (uint64, error) {
+	units := []struct {
+		suffix string
+		factor uint64
+	}{
+		{"TB", 1 << 40}, {"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10},
+		{"T", 1 << 40}, {"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10}, {"B", 1},
+	}
+	value := strings.ToUpper(strings.TrimSpace(size))
+	value = strings.Replace(value, "IB", "B", 1)
+	factor := uint64(1)
+	for _, unit := range units {
+		if strings.HasSuffix(value, unit.suffix) {
+			value, factor = strings.TrimSuffix(value, unit.suffix), unit.factor
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size '%s'", size)
+	}
+	return uint64(n * float64(factor)), nil
+}
+
+// watchThresholds dumps a heap profile and all goroutine stacks once the heap or the goroutine count cross their limits
+func watchThresholds(ctx context.Context, config watchdogConfig) {
+	t := time.NewTicker(watchdogInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			checkThresholds(config)
+		}
+	}
+}
+
+// checkThresholds writes the dumps if a limit is crossed and the cooldown has passed, it reports whether it did
+func checkThresholds(config watchdogConfig) bool {
+	reasons := make([]string, 0, 2)
+	fields := logrus.Fields{}
+	if config.heapLimit > 0 {
+		var mem runtime.MemStats
+		runtime.ReadMemStats(&mem)
+		if mem.HeapInuse >= config.heapLimit {
+			reasons = append(reasons, "heap")
+			fields["heap_inuse"] = mem.HeapInuse
+			fields["heap_limit"] = config.heapLimit
+		}
+	}
+	if config.goroutineLimit > 0 {
+		if routines := runtime.NumGoroutine(); routines >= config.goroutineLimit {
+			reasons = append(reasons, "goroutines")
+			fields["routines"] = routines
+			fields["routines_limit"] = config.goroutineLimit
+		}
+	}
+	last := lastWatchdogDump.Load()
+	if len(reasons) == 0 || (last != 0 && time.Since(time.Unix(0, last)) < config.cooldown) {
+		return false
+	}
+	lastWatchdogDump.Store(time.Now().UnixNano())
+
+	log := GetLoggerForPrefix("watchdog").WithFields(fields)
+	if file, err := config.profiles.write("heapwatch", ".pprof", writeNamedProfile("heap", 0)); err == nil {
+		log = log.WithField("heap_profile", file)
+	} else {
+		log.Errorf("could not write heap profile: %v", err)
+	}
+	if file, err := config.profiles.write("goroutines", ".txt", writeNamedProfile("goroutine", 2)); err == nil {
+		log = log.WithField("goroutine_dump", file)
+	} else {
+		log.Errorf("could not write goroutine dump: %v", err)
+	}
+	log.Warnf("%s over threshold, dumped heap profile and goroutines", strings.Join(reasons, " and "))
+	return true
+}
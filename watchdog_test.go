@@ -0,0 +1,53 @@
+package env_logger
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseByteSize(t *testing.T) {
+	tests := map[string]uint64{
+		"512":    512,
+		"100B":   100,
+		"4k":     4 << 10,
+		"512MB":  512 << 20,
+		"1GB":    1 << 30,
+		"1.5G":   3 << 29,
+		"2GiB":   2 << 30,
+		" 1 TB ": 1 << 40,
+	}
+	for size, expected := range tests {
+		value, err := parseByteSize(size)
+		if assert.NoError(t, err, size) {
+			assert.Equal(t, expected, value, size)
+		}
+	}
+	for _, size := range []string{"", "GB", "-1MB", "lots"} {
+		_, err := parseByteSize(size)
+		assert.Error(t, err, size)
+	}
+}
+
+func TestCheckThresholds(t *testing.T) {
+	configureQuietLoggers(t, "info")
+	saved := lastWatchdogDump.Load()
+	lastWatchdogDump.Store(0)
+	defer lastWatchdogDump.Store(saved)
+
+	out := tempProfileOutput(t, 10)
+	assert.False(t, checkThresholds(watchdogConfig{goroutineLimit: 1 << 20, cooldown: time.Hour, profiles: out}))
+
+	config := watchdogConfig{goroutineLimit: 1, cooldown: time.Hour, profiles: out}
+	require.True(t, checkThresholds(config))
+	heap, _ := filepath.Glob(out.pattern("heapwatch", ".pprof"))
+	goroutines, _ := filepath.Glob(out.pattern("goroutines", ".txt"))
+	assert.Len(t, heap, 1)
+	assert.Len(t, goroutines, 1)
+
+	// no second dump during the cooldown
+	assert.False(t, checkThresholds(config))
+}